@@ -0,0 +1,3601 @@
+//**************************************************************
+//
+// N4L parser and compiler, as a library
+//
+// Parse N4L notes into an in-memory SSTorytime graph session,
+// collecting every problem as a Diagnostic instead of stopping
+// at the first one. The N4L command (src/N4L.go) is a client.
+//
+//**************************************************************
+
+package N4L
+
+import (
+	"strings"
+	"errors"
+	"os"
+	"io"
+	"bufio"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+	"regexp"
+	"sort"
+	"path/filepath"
+	"strconv"
+
+        SST "SSTorytime"
+)
+
+//**************************************************************
+// Parsing state variables
+//**************************************************************
+
+const (
+	ALPHATEXT = 'x'
+	NON_ASCII_LQUOTE = '“'
+	NON_ASCII_RQUOTE = '”'
+        HAVE_PLUS = 11
+        HAVE_MINUS = 22
+	ROLE_ABBR = 33
+	LARGE_FILE = 500000
+
+	SEQ_UNKNOWN = false
+	SEQ_START = true
+
+	ROLE_EVENT = 1
+	ROLE_RELATION = 2
+	ROLE_SECTION = 3
+	ROLE_CONTEXT = 4
+	ROLE_CONTEXT_ADD = 5
+	ROLE_CONTEXT_SUBTRACT = 6
+	ROLE_BLANK_LINE = 7
+	ROLE_LINE_ALIAS = 8
+	ROLE_LOOKUP = 9
+
+	SEQUENCE_RELN = "then"
+	SEQUENCE_RELN_INV = "from"
+
+	WORD_MISTAKE_LEN = 2 // a string shorter than this is probably a mistake
+
+	WARN_NOTE_TO_SELF = "WARNING: Found a possible note to self in the text"
+	WARN_INADVISABLE_CONTEXT_EXPRESSION = "WARNING: Inadvisably complex/parenthetic context expression - simplify?"
+	WARN_CHAPTER_CLASS_MIXUP="WARNING: possible space between class cancellation -:: <class> :: ambiguous chapter name, in: "
+	ERR_CHAPTER_COMMA="You shouldn't use commas in the chapter title (ambiguous separator): "
+
+	ERR_NO_SUCH_FILE_FOUND = "No file found in the name "
+	ERR_MISSING_EVENT = "Missing item? Dangling section, relation, or context"
+	ERR_MISSING_SECTION = "Declarations outside a section or chapter"
+	ERR_NO_SUCH_ALIAS = "No such alias or \" reference exists to fill in - aborting"
+	ERR_MISSING_ITEM_SOMEWHERE = "Missing item, empty string, perhaps a missing ditto or variable reference"
+	ERR_MISSING_ITEM_RELN = "Missing item or double relation"
+	ERR_MISMATCH_QUOTE = "Apparent missing or mismatch in ', \" or ( )"
+	ERR_ILLEGAL_CONFIGURATION = "Error in configuration, no such section"
+	ERR_BAD_LABEL_OR_REF = "Badly formed label or reference (@label becomes $label.n) in "
+	ERR_ILLEGAL_QUOTED_STRING_OR_REF = "WARNING: Something wrong, bad quoted string or mistaken back reference. Double-quoted strings should not have a space after leading quote, as it can be confused with \" ditto symbol"
+	ERR_ANNOTATION_BAD = "Annotation marker should be short mark of non-space, non-alphanumeric character "
+	ERR_BAD_ABBRV = "abbreviation out of place"
+	ERR_BAD_ALIAS_REFERENCE = "Alias references start from $name.1"
+	ERR_ANNOTATION_MISSING = "Missing non-alphnumeric annotation marker or stray relation"
+	ERR_ANNOTATION_REDEFINE = "Redefinition of annotation character"
+	ERR_SIMILAR_NO_SIGN = "Arrows for similarity do not have signs, they are directionless"
+	ERR_ARROW_SELFLOOP = "Arrow's origin points to itself"
+	ERR_ARR_REDEFINITION="Warning: Redefinition of arrow "
+	ERR_NEGATIVE_WEIGHT = "Arrow relation has a negative weight, which is disallowed. Use a NOT relation if you want to signify inhibition: "
+	ERR_TOO_MANY_WEIGHTS = "More than one weight value in the arrow relation "
+        ERR_STRAY_PAREN="Stray ) in an event/item - illegal character"
+	ERR_MISSING_LINE_LABEL_IN_REFERENCE="Missing a line label in reference, should be in the form $label.n"
+	ERR_NON_WORD_WHITE="Non word (whitespace) character after an annotation: "
+	ERR_SHORT_WORD="Short word, possible mistake or unquoted annotation: "
+	ERR_ILLEGAL_ANNOT_CHAR="Cannot use +/- reserved tokens for annotation"
+	ERR_READ_FAILED="Unable to read input: "
+
+	// Arrow configuration
+
+	CONFIG_DIR = "SSTconfig"
+	LOCAL_CONFIG_DIR = "SSTconfig.local" // a project's own arrows, on top of the shared ones
+
+	DIRECTIVE_ARROWS = "%arrows" // arrow definitions in the notes, as in SSTconfig,
+	DIRECTIVE_END = "%end"       // up to this line
+
+	ERR_UNTERMINATED_ARROWS = "Missing %end after %arrows"
+	ERR_STRAY_END = "Found %end without %arrows"
+
+	// Splitting notes across files
+
+	DIRECTIVE_INCLUDE = "%include"  // %include glossary.n4l, relative to this file
+	DIRECTIVE_EXPORT = "%export"    // %export glossary, so later files can use $glossary.n
+
+	ERR_INCLUDE_CYCLE = "File includes itself, directly or through others: "
+	ERR_BAD_DIRECTIVE = "Missing file or alias name after "
+	ERR_NO_SUCH_EXPORT = "Can't export an alias that isn't defined in this file: "
+	WARN_EXPORT_REPLACED = "WARNING: Exported alias replaces one exported by an earlier file: "
+)
+
+// The files in SSTconfig that every installation has
+
+var CONFIG_FILES = []string{"arrows-LT-1.sst","arrows-NR-0.sst","arrows-CN-2.sst","arrows-EP-3.sst","annotations.sst"}
+
+//**************************************************************
+// Diagnostics
+//**************************************************************
+
+const (
+	SEVERITY_ERROR = 1   // the notes can't be trusted, don't upload them
+	SEVERITY_WARNING = 2 // probably a mistake, but the graph is usable
+)
+
+//**************************************************************
+
+type Diagnostic struct {
+
+	File     string `json:"file"`
+	Line     int    `json:"line"`     // from 1
+	Column   int    `json:"column"`   // from 1, counted in runes
+	Severity int    `json:"severity"`
+	Code     string `json:"code"`     // stable name of the message, e.g. ERR_MISSING_EVENT
+	Message  string `json:"message"`
+}
+
+//**************************************************************
+
+type DiagnosticCode struct {
+
+	Code     string
+	Severity int
+}
+
+//**************************************************************
+
+// Every message has a stable code, named after its constant. Errors are
+// the problems that used to stop N4L, warnings are the ones it carried on after
+
+var DIAGNOSTIC_CODES = map[string]DiagnosticCode{
+
+	WARN_NOTE_TO_SELF:                   {"WARN_NOTE_TO_SELF",SEVERITY_WARNING},
+	WARN_INADVISABLE_CONTEXT_EXPRESSION: {"WARN_INADVISABLE_CONTEXT_EXPRESSION",SEVERITY_WARNING},
+	WARN_CHAPTER_CLASS_MIXUP:            {"WARN_CHAPTER_CLASS_MIXUP",SEVERITY_ERROR},
+	ERR_CHAPTER_COMMA:                   {"ERR_CHAPTER_COMMA",SEVERITY_ERROR},
+	ERR_NO_SUCH_FILE_FOUND:              {"ERR_NO_SUCH_FILE_FOUND",SEVERITY_ERROR},
+	ERR_MISSING_EVENT:                   {"ERR_MISSING_EVENT",SEVERITY_WARNING},
+	ERR_MISSING_SECTION:                 {"ERR_MISSING_SECTION",SEVERITY_ERROR},
+	ERR_NO_SUCH_ALIAS:                   {"ERR_NO_SUCH_ALIAS",SEVERITY_ERROR},
+	ERR_MISSING_ITEM_SOMEWHERE:          {"ERR_MISSING_ITEM_SOMEWHERE",SEVERITY_ERROR},
+	ERR_MISSING_ITEM_RELN:               {"ERR_MISSING_ITEM_RELN",SEVERITY_ERROR},
+	ERR_MISMATCH_QUOTE:                  {"ERR_MISMATCH_QUOTE",SEVERITY_ERROR},
+	ERR_ILLEGAL_CONFIGURATION:           {"ERR_ILLEGAL_CONFIGURATION",SEVERITY_ERROR},
+	ERR_BAD_LABEL_OR_REF:                {"ERR_BAD_LABEL_OR_REF",SEVERITY_ERROR},
+	ERR_ILLEGAL_QUOTED_STRING_OR_REF:    {"ERR_ILLEGAL_QUOTED_STRING_OR_REF",SEVERITY_ERROR},
+	ERR_ANNOTATION_BAD:                  {"ERR_ANNOTATION_BAD",SEVERITY_WARNING},
+	ERR_BAD_ABBRV:                       {"ERR_BAD_ABBRV",SEVERITY_ERROR},
+	ERR_BAD_ALIAS_REFERENCE:             {"ERR_BAD_ALIAS_REFERENCE",SEVERITY_ERROR},
+	ERR_ANNOTATION_MISSING:              {"ERR_ANNOTATION_MISSING",SEVERITY_WARNING},
+	ERR_ANNOTATION_REDEFINE:             {"ERR_ANNOTATION_REDEFINE",SEVERITY_ERROR},
+	ERR_SIMILAR_NO_SIGN:                 {"ERR_SIMILAR_NO_SIGN",SEVERITY_ERROR},
+	ERR_ARROW_SELFLOOP:                  {"ERR_ARROW_SELFLOOP",SEVERITY_ERROR},
+	ERR_ARR_REDEFINITION:                {"ERR_ARR_REDEFINITION",SEVERITY_WARNING},
+	ERR_NEGATIVE_WEIGHT:                 {"ERR_NEGATIVE_WEIGHT",SEVERITY_ERROR},
+	ERR_TOO_MANY_WEIGHTS:                {"ERR_TOO_MANY_WEIGHTS",SEVERITY_ERROR},
+	ERR_STRAY_PAREN:                     {"ERR_STRAY_PAREN",SEVERITY_ERROR},
+	ERR_MISSING_LINE_LABEL_IN_REFERENCE: {"ERR_MISSING_LINE_LABEL_IN_REFERENCE",SEVERITY_ERROR},
+	ERR_NON_WORD_WHITE:                  {"ERR_NON_WORD_WHITE",SEVERITY_WARNING},
+	ERR_SHORT_WORD:                      {"ERR_SHORT_WORD",SEVERITY_WARNING},
+	ERR_ILLEGAL_ANNOT_CHAR:              {"ERR_ILLEGAL_ANNOT_CHAR",SEVERITY_ERROR},
+	ERR_READ_FAILED:                     {"ERR_READ_FAILED",SEVERITY_ERROR},
+	ERR_UNTERMINATED_ARROWS:             {"ERR_UNTERMINATED_ARROWS",SEVERITY_ERROR},
+	ERR_STRAY_END:                       {"ERR_STRAY_END",SEVERITY_ERROR},
+	ERR_INCLUDE_CYCLE:                   {"ERR_INCLUDE_CYCLE",SEVERITY_ERROR},
+	ERR_BAD_DIRECTIVE:                   {"ERR_BAD_DIRECTIVE",SEVERITY_ERROR},
+	ERR_NO_SUCH_EXPORT:                  {"ERR_NO_SUCH_EXPORT",SEVERITY_ERROR},
+	WARN_EXPORT_REPLACED:                {"WARN_EXPORT_REPLACED",SEVERITY_WARNING},
+	WARN_UNUSED_ALIAS:                   {"WARN_UNUSED_ALIAS",SEVERITY_WARNING},
+	WARN_UNUSED_ARROW:                   {"WARN_UNUSED_ARROW",SEVERITY_WARNING},
+	WARN_ISOLATED_ITEM:                  {"WARN_ISOLATED_ITEM",SEVERITY_WARNING},
+	WARN_CONTRADICTORY_ARROWS:           {"WARN_CONTRADICTORY_ARROWS",SEVERITY_WARNING},
+	WARN_LONG_CONTEXT:                   {"WARN_LONG_CONTEXT",SEVERITY_WARNING},
+	SST.ERR_NO_SUCH_ARROW:               {"ERR_NO_SUCH_ARROW",SEVERITY_ERROR},
+	SST.WARN_DIFFERENT_CAPITALS:         {"WARN_DIFFERENT_CAPITALS",SEVERITY_WARNING},
+}
+
+//**************************************************************
+// Parser state
+//**************************************************************
+
+type Config struct {
+
+	ConfigFiles []string // arrow and annotation definitions, nil means FindConfigFiles()
+	Verbose     bool     // explain the parse on stdout
+	Diagnostic  bool     // also log to test_output/<file>_test_log for self-tests
+	Progress    bool     // show signs of life while parsing large files
+
+	Report func(Diagnostic) // if set, called as each problem is found
+}
+
+//**************************************************************
+
+type Graph struct {
+
+	SST.PoSST                     // the compiled nodes, links, arrows, contexts and page map
+	Annotations map[string]string // annotation marks and their arrows, from the configuration
+	Sources     Sources           // where things were written, for lint
+
+	Exports map[string][]string // aliases exported with %export, for all later files
+	Files   map[string]bool     // files read so far, by absolute path, so each is read once
+}
+
+//**************************************************************
+
+type Place struct {
+
+	File   string
+	Line   int
+	Column int
+}
+
+//**************************************************************
+
+type Mention struct {
+
+	Place
+	Text string
+	Used bool // for aliases, referred to as $alias.n in the same file
+}
+
+//**************************************************************
+
+type SourceLink struct {
+
+	From SST.NodePtr
+	Arr  SST.ArrowPtr
+	To   SST.NodePtr
+}
+
+//**************************************************************
+
+type Export struct {
+
+	Alias string
+	Pos   int // of the %export, for diagnostics
+}
+
+//**************************************************************
+
+type Sources struct {
+
+	Items    map[SST.NodePtr]Place  // where each item was first written
+	Links    map[SourceLink]Place   // where each link was first written, both ways
+	Arrows   map[SST.ArrowPtr]Place // where each arrow was defined
+	Aliases  []Mention              // every @alias
+	Contexts []Mention              // every :: context :: expression
+	Chapters []Mention              // every -chapter
+}
+
+//**************************************************************
+
+type Parser struct {
+
+	// The state of one file, formerly package globals in N4L.go
+
+	Graph       *Graph
+	Config      Config
+	Diagnostics []Diagnostic
+
+	File        string
+	DiagFile    string
+	Src         []rune
+	Pos         int  // where the current token starts, for diagnostics
+	Abandon     bool // an error spoiled the rest of this line
+	Configuring bool
+	NoSection   bool // already reported items outside a chapter
+	BadSection  bool // already reported an unknown configuration section
+
+	LineNum         int
+	LineItemCache   map[string][]string  // contains current and labelled line elements
+	LineItemRefs    []SST.NodePtr        // contains current line integer references
+	LineRelnCache   map[string][]SST.Link
+	LineItemState   int
+	LineAlias       string
+	LineItemCounter int
+	LineRelnCounter int
+	LinePath        []SST.Link
+
+	FwdArrow string
+	BwdArrow string
+	FwdIndex SST.ArrowPtr
+	BwdIndex SST.ArrowPtr
+
+	ContextState map[string]bool
+	SectionState string
+
+	// Sequence mode state
+
+	SequenceMode   bool
+	SequenceStart  bool
+	LastInSequence string
+
+	SignOfLife  int
+	SignsOfLife bool
+
+	Including []string  // absolute paths of the files that included this one
+	Exported  []Export  // %export names, checked at the end of the file
+
+	// For lint, kept until the end of the file
+
+	Aliases   []Mention
+	AliasUsed map[string]bool
+
+	HerePos  int // Here() counts lines incrementally from here
+	HereLine int
+	HereCol  int
+}
+
+//**************************************************************
+// BEGIN
+//**************************************************************
+
+func Parse(r io.Reader,filename string,cfg Config) (*Graph,[]Diagnostic) {
+
+	// Parse one file into a new in-memory graph
+
+	g,diags := NewGraph(SST.OpenMemory(),cfg)
+
+	return g,append(diags,ParseInto(g,r,filename,cfg)...)
+}
+
+//**************************************************************
+
+func NewGraph(sst SST.PoSST,cfg Config) (*Graph,[]Diagnostic) {
+
+	// Prepare a graph session, with or without a database, by adding the
+	// built in arrows and reading the arrow configuration
+
+	var g Graph
+
+	g.PoSST = sst
+	g.Annotations = make(map[string]string)
+	g.Sources.Items = make(map[SST.NodePtr]Place)
+	g.Sources.Links = make(map[SourceLink]Place)
+	g.Exports = make(map[string][]string)
+	g.Files = make(map[string]bool)
+	g.Sources.Arrows = make(map[SST.ArrowPtr]Place)
+
+	AddMandatory(g.PoSST)
+
+	config := cfg.ConfigFiles
+
+	if config == nil {
+		config = FindConfigFiles()
+	}
+
+	var diags []Diagnostic
+
+	for input := 0; input < len(config); input++ {
+
+		p := NewParser(&g,config[input],cfg)
+		p.Configuring = true
+
+		text,err := ReadFile(config[input])
+
+		if err != nil {
+			ParseError(p,ERR_NO_SUCH_FILE_FOUND,config[input])
+		} else {
+			ParseConfig(p,text)
+		}
+
+		diags = append(diags,p.Diagnostics...)
+	}
+
+	return &g,diags
+}
+
+//**************************************************************
+
+func ParseFile(g *Graph,filename string,cfg Config) []Diagnostic {
+
+	// Add the notes in a file to the graph, unless they were already
+	// pulled in by an %include
+
+	if abs,err := filepath.Abs(filename); err == nil && g.Files != nil {
+		if g.Files[abs] {
+			return nil
+		}
+		g.Files[abs] = true
+	}
+
+	file,err := os.Open(filename)
+
+	if err != nil {
+		p := NewParser(g,filename,cfg)
+		ParseError(p,ERR_NO_SUCH_FILE_FOUND,filename)
+		return p.Diagnostics
+	}
+
+	defer file.Close()
+
+	return ParseInto(g,file,filename,cfg)
+}
+
+//**************************************************************
+
+func ParseInto(g *Graph,r io.Reader,filename string,cfg Config) []Diagnostic {
+
+	// Add the notes read from r to the graph, naming them filename in diagnostics
+
+	p := NewParser(g,filename,cfg)
+
+	text,err := ReadRunes(r)
+
+	if err != nil {
+		ParseError(p,ERR_READ_FAILED,err.Error())
+		return p.Diagnostics
+	}
+
+	ParseText(p,text)
+
+	return p.Diagnostics
+}
+
+//**************************************************************
+
+func ParseText(p *Parser,text []rune) {
+
+	// The notes of one file, already read
+
+	if len(text) > LARGE_FILE && p.Config.Progress {
+		p.SignsOfLife = true
+	}
+
+	if !p.Config.Verbose && p.SignsOfLife {
+		fmt.Printf("[%s] is a large file. This will take a while...\n",p.File)
+	}
+
+	ParseN4L(p,text)
+
+	ExportAliases(p)
+
+	// Aliases only live as long as the file, so now we know which were used
+
+	for a := range p.Aliases {
+		p.Aliases[a].Used = p.AliasUsed[p.Aliases[a].Text]
+	}
+
+	p.Graph.Sources.Aliases = append(p.Graph.Sources.Aliases,p.Aliases...)
+}
+
+//**************************************************************
+
+func NewParser(g *Graph,filename string,cfg Config) *Parser {
+
+	var p Parser
+
+	p.Graph = g
+	p.Config = cfg
+	p.File = filename
+	p.DiagFile = DiagnosticName(filename)
+
+	Box(&p,"Parsing new file",filename)
+
+	p.LineItemState = ROLE_BLANK_LINE
+	p.LineNum = 1
+	p.LineItemCache = make(map[string][]string)
+	p.LineRelnCache = make(map[string][]SST.Link)
+	p.LineItemCounter = 1
+	p.ContextState = make(map[string]bool)
+	p.AliasUsed = make(map[string]bool)
+	p.HereLine,p.HereCol = 1,1
+
+	Box(&p,"Reset context","any")
+	ContextEval(&p,"any","=")
+
+	return &p
+}
+
+//**************************************************************
+
+func HasErrors(diags []Diagnostic) bool {
+
+	for d := range diags {
+		if diags[d].Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+
+	return false
+}
+
+//**************************************************************
+// N4L configuration
+//**************************************************************
+
+func ParseConfig(p *Parser,src []rune) {
+
+	ParseConfigRange(p,src,0,len(src))
+}
+
+//**************************************************************
+
+func ParseConfigRange(p *Parser,src []rune,from,to int) {
+
+	var token string
+
+	p.Src = src
+
+	for pos := from; pos < to; {
+
+		pos = SkipWhiteSpace(p,src,pos)
+		p.Pos = pos
+		token,pos = GetConfigToken(p,src,pos)
+
+		ClassifyConfigRole(p,token)
+
+		if p.Abandon {
+			pos = AbandonLine(p,src,pos)
+		}
+	}
+}
+
+//**************************************************************
+
+func GetConfigToken(p *Parser,src []rune, pos int) (string,int) {
+
+	// Handle concatenation of words/lines and separation of types
+
+	var token string
+
+	if pos >= len(src) {
+		return "", pos
+	}
+
+	switch (src[pos]) {
+
+	case '+':
+		token,pos = ReadToLast(p,src,pos,ALPHATEXT)
+
+	case '-':
+		token,pos = ReadToLast(p,src,pos,ALPHATEXT)
+
+	case '(':
+		token,pos = ReadToLast(p,src,pos,')')  // alias
+
+	case '#':
+		return "",pos
+
+	case '/':
+		if src[pos+1] == '/' {
+			return "",pos
+		}
+
+	default: // similarity
+		token,pos = ReadToLast(p,src,pos,ALPHATEXT)
+
+	}
+
+	return token, pos
+}
+
+//**************************************************************
+
+func ClassifyConfigRole(p *Parser,token string) {
+
+	if len(token) == 0 {
+		return
+	}
+
+	sst := p.Graph.PoSST
+
+	if token[0] == '-' && p.LineItemState == ROLE_BLANK_LINE {
+		p.SectionState = strings.TrimSpace(token[1:])
+		p.BadSection = false
+		Box(p,"Configuration of",p.SectionState)
+		p.LineItemState = ROLE_SECTION
+		return
+	}
+
+	switch p.SectionState {
+
+	case "leadsto","contains","properties":
+
+		switch token[0] {
+
+		case '+':
+			p.FwdArrow = strings.TrimSpace(token[1:])
+			p.LineItemState = HAVE_PLUS
+			Diag(p,"fwd arrow in",p.SectionState, token)
+
+		case '-':
+			p.BwdArrow = strings.TrimSpace(token[1:])
+			p.LineItemState = HAVE_MINUS
+			Diag(p,"bwd arrow in",p.SectionState, token)
+
+		case '(':
+			reln := token[1:len(token)-1]
+			reln = strings.TrimSpace(reln)
+
+			if p.LineItemState == HAVE_MINUS {
+				p.BwdIndex = SST.InsertArrowDirectory(sst,p.SectionState,reln,p.BwdArrow,"-")
+				ArrowCollision(p,p.BwdIndex,reln,p.BwdArrow)
+				RecordArrow(p,p.BwdIndex)
+				SST.InsertInverseArrowDirectory(sst,p.FwdIndex,p.BwdIndex)
+				PVerbose(p,"In",p.SectionState,"short name",reln,"for",p.BwdArrow,", direction","-")
+			} else if p.LineItemState == HAVE_PLUS {
+				p.FwdIndex = SST.InsertArrowDirectory(sst,p.SectionState,reln,p.FwdArrow,"+")
+				ArrowCollision(p,p.FwdIndex,reln,p.FwdArrow)
+				RecordArrow(p,p.FwdIndex)
+				PVerbose(p,"In",p.SectionState,"short name",reln,"for",p.FwdArrow,", direction","+")
+			} else {
+				ParseError(p,ERR_BAD_ABBRV,"")
+				p.Abandon = true
+			}
+		}
+
+	case "similarity":
+
+		switch token[0] {
+
+		case '(':
+			reln := token[1:len(token)-1]
+			reln = strings.TrimSpace(reln)
+
+			if p.LineItemState == HAVE_MINUS {
+				index := SST.InsertArrowDirectory(sst,p.SectionState,reln,p.BwdArrow,"both")
+				ArrowCollision(p,index,reln,p.BwdArrow)
+				SST.InsertInverseArrowDirectory(sst,index,index)
+				RecordArrow(p,index)
+				PVerbose(p,"In",p.SectionState,reln,"for",p.BwdArrow,", direction","both")
+			} else {
+				PVerbose(p,p.SectionState,"abbreviation out of place")
+			}
+
+		case '+','-':
+			ParseError(p,ERR_SIMILAR_NO_SIGN,"")
+			p.Abandon = true
+
+		default:
+			similarity := strings.TrimSpace(token)
+			p.FwdArrow = similarity
+			p.BwdArrow = similarity
+			p.LineItemState = HAVE_MINUS
+		}
+
+	case "annotations":
+
+		switch token[0] {
+
+		case '(':
+			if p.LineItemState != HAVE_PLUS {
+				ParseError(p,ERR_ANNOTATION_MISSING,"")
+			}
+
+			p.FwdArrow = StripParen(token)
+			PVerbose(p,"Annotation marker",p.LastInSequence,"defined as arrow:",p.FwdArrow)
+
+			value,defined := p.Graph.Annotations[p.LastInSequence]
+
+			if defined && value != p.FwdArrow {
+				ParseError(p,ERR_ANNOTATION_REDEFINE,"")
+				p.Abandon = true
+				return
+			}
+
+			p.Graph.Annotations[p.LastInSequence] = p.FwdArrow
+			p.LineItemState = ROLE_BLANK_LINE
+
+		default:
+
+			for r := range token {
+				if unicode.IsLetter(rune(token[r])) {
+					ParseError(p,ERR_ANNOTATION_BAD,"")
+				}
+			}
+
+			if token[0] == '+' || token[0] == '-' {
+				ParseError(p,ERR_ILLEGAL_ANNOT_CHAR,"")
+				p.Abandon = true
+				return
+			}
+
+			Diag(p,"Markup character defined in",p.SectionState, token)
+			p.LineItemState = HAVE_PLUS
+			p.LastInSequence = token
+
+		}
+
+	default:
+		// Say so once, not for every line of the section
+
+		if !p.BadSection {
+			ParseError(p,ERR_ILLEGAL_CONFIGURATION," "+p.SectionState)
+			p.BadSection = true
+		}
+		p.Abandon = true
+	}
+}
+
+//**************************************************************
+
+func ArrowCollision(p *Parser,arr SST.ArrowPtr,short,long string) {
+
+	// Say where the names were used before, which may be another file
+
+	if arr >= 0 {
+		return
+	}
+
+	sst := p.Graph.PoSST
+
+	prev,found := sst.ArrowShortDir[short]
+
+	if !found {
+		prev,found = sst.ArrowLongDir[long]
+	}
+
+	where := "somewhere"
+
+	if found {
+		a := sst.ArrowDirectory[prev]
+		where = fmt.Sprintf("by long \"%s\"/short \"%s\"",a.Long,a.Short)
+
+		if place,known := p.Graph.Sources.Arrows[prev]; known {
+			where += fmt.Sprintf(" at %s:%d",place.File,place.Line)
+		} else {
+			where += " (built in)"
+		}
+	}
+
+	ParseError(p,ERR_ARR_REDEFINITION,"long \""+long+"\"/"+"short \""+short+"\" seems to be previously used "+where)
+}
+
+//**************************************************************
+
+func GetLinkArrowByName(p *Parser,token string) SST.Link {
+
+	// Return a preregistered link/arrow ptr bythe name of a link.
+	// On error the line is abandoned and the link is not to be used
+
+	var reln []string
+	var weight float32 = 1
+	var weightcount int
+	var ctx []string
+	var name string
+	var link SST.Link
+
+	sst := p.Graph.PoSST
+
+	if token[0] == '(' {
+		name = token[1:len(token)-1]
+	} else {
+		name = token
+	}
+
+	name = strings.TrimSpace(name)
+
+	if strings.Contains(name,",") {
+		reln = strings.Split(name,",")
+		name = reln[0]
+
+		// look at any comma separated notes after the arrow name
+		for i := 1; i < len(reln); i++ {
+
+			v, err := strconv.ParseFloat(reln[i], 32)
+
+			if err == nil {
+				if weight < 0 {
+					ParseError(p,ERR_NEGATIVE_WEIGHT,token)
+					p.Abandon = true
+					return link
+				}
+				if weightcount > 1 {
+					ParseError(p,ERR_TOO_MANY_WEIGHTS,token)
+					p.Abandon = true
+					return link
+				}
+				weight = float32(v)
+				weightcount++
+			} else {
+				ctx = append(ctx,reln[i])
+			}
+		}
+	}
+
+	// First check if this is an alias/short name
+
+	ptr, ok := sst.ArrowShortDir[name]
+
+	// If not, then check longname
+
+	if !ok {
+		ptr, ok = sst.ArrowLongDir[name]
+
+		if !ok {
+			ParseError(p,SST.ERR_NO_SUCH_ARROW,"("+name+")")
+			p.Abandon = true
+			return link
+		}
+	}
+
+	link.Arr = ptr
+	link.Wgt = weight
+	link.Ctx = SST.RegisterContext(sst,p.ContextState,ctx)
+	return link
+}
+
+//**************************************************************
+
+func LookupAlias(p *Parser,alias string, counter int) string {
+
+	value,ok := p.LineItemCache[alias]
+
+	if !ok {
+		value,ok = p.Graph.Exports[alias] // from another file
+	}
+
+	if !ok || counter > len(value) {
+		ParseError(p,ERR_NO_SUCH_ALIAS,"")
+		p.Abandon = true
+		return ""
+	}
+
+	p.AliasUsed[alias] = true
+
+	return value[counter-1]
+}
+
+//**************************************************************
+
+func ResolveAliasedItem(p *Parser,token string) string {
+
+	// split $alias.n into (alias string,n int)
+
+	if! strings.Contains(token,".") {
+		// just a dollar amount
+		return token
+	}
+
+	var contig string
+	fmt.Sscanf(token,"%s",&contig)
+
+	if len(contig) == 1 {
+		return token
+	}
+
+	if contig == "$$" {
+		return token
+	}
+
+	split := strings.Split(token[1:],".")
+
+	if len(split) < 2 {
+		ParseError(p,ERR_MISSING_LINE_LABEL_IN_REFERENCE,"")
+		p.Abandon = true
+		return ""
+	}
+
+	name := strings.TrimSpace(split[0])
+
+	var number int = 0
+	fmt.Sscanf(split[1],"%d",&number)
+
+	if number < 1 {
+		ParseError(p,ERR_BAD_ALIAS_REFERENCE,"")
+		p.Abandon = true
+		return ""
+	}
+
+	return LookupAlias(p,name,number)
+}
+
+//**************************************************************
+// N4L language
+//**************************************************************
+
+func ParseN4L(p *Parser,src []rune) {
+
+	var token string
+
+	p.Src = src
+
+	for pos := 0; pos < len(src); {
+
+		pos = SkipWhiteSpace(p,src,pos)
+		p.Pos = pos
+
+		if p.LineItemState == ROLE_BLANK_LINE && IsDirective(src,pos) {
+			pos = Directive(p,src,pos)
+			continue
+		}
+
+		token,pos = GetToken(p,src,pos)
+
+		if !p.Abandon {
+			ClassifyTokenRole(p,token)
+		}
+
+		if p.Abandon {
+			pos = AbandonLine(p,src,pos)
+		}
+	}
+
+	p.Pos = len(src)
+
+	if Dangler(p) {
+		ParseError(p,ERR_MISSING_EVENT,"")
+	}
+}
+
+//**************************************************************
+
+func AbandonLine(p *Parser,src []rune,pos int) int {
+
+	// Recover from an error by skipping what's left of the line, so we can
+	// go on to report problems further down. Don't complain that the line
+	// dangles as well
+
+	for ; pos < len(src) && src[pos] != '\n'; pos++ {
+	}
+
+	if Dangler(p) {
+		if len(p.LineItemCache["THIS"]) > 0 {
+			p.LineItemState = ROLE_EVENT
+		} else {
+			p.LineItemState = ROLE_BLANK_LINE
+		}
+	}
+
+	p.Abandon = false
+
+	return pos
+}
+
+//**************************************************************
+
+func SkipWhiteSpace(p *Parser,src []rune, pos int) int {
+
+	for ; pos < len(src) && IsWhiteSpace(src[pos],src[pos]); pos++ {
+
+		if src[pos] == '\n' {
+			p.Pos = pos
+			UpdateLastLineCache(p)
+		} else {
+
+			if src[pos] == '#' || (src[pos] == '/' && src[pos+1] == '/') {
+
+				for ; pos < len(src) && src[pos] != '\n'; pos++ {
+				}
+
+				p.Pos = pos
+				UpdateLastLineCache(p)
+			}
+		}
+	}
+
+	return pos
+}
+
+//**************************************************************
+
+func AddMandatory(sst SST.PoSST) {
+
+	SST.RegisterContext(sst,nil,[]string{"any"})
+
+	// empty link for orphans to retain context - NB, this convention is used a lot in context handling EMPTY == LEADSTO
+
+	arr := SST.InsertArrowDirectory(sst,"leadsto","empty","debug","+")
+	inv := SST.InsertArrowDirectory(sst,"leadsto","void","unbug","-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+	// reserved for text2N4L
+
+	arr = SST.InsertArrowDirectory(sst,"contains",SST.CONT_FINDS_S,SST.CONT_FINDS_L,"+")
+        inv = SST.InsertArrowDirectory(sst,"contains",SST.INV_CONT_FOUND_IN_S,SST.INV_CONT_FOUND_IN_L,"-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+	arr = SST.InsertArrowDirectory(sst,"similarity",SST.NEAR_FRAG_S,SST.NEAR_FRAG_L,"+")
+        inv = SST.InsertArrowDirectory(sst,"similarity",SST.INV_NEAR_FRAG_IN_S,SST.INV_NEAR_FRAG_IN_L,"-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+	arr = SST.InsertArrowDirectory(sst,"properties",SST.EXPR_INTENT_S,SST.EXPR_INTENT_L,"+")
+        inv = SST.InsertArrowDirectory(sst,"properties",SST.INV_EXPR_INTENT_S,SST.INV_EXPR_INTENT_L,"-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+	arr = SST.InsertArrowDirectory(sst,"properties",SST.EXPR_AMBIENT_S,SST.EXPR_AMBIENT_L,"+")
+        inv = SST.InsertArrowDirectory(sst,"properties",SST.INV_EXPR_AMBIENT_S,SST.INV_EXPR_AMBIENT_L,"-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+	// Reserved for special UX handling
+
+	arr = SST.InsertArrowDirectory(sst,"leadsto",SEQUENCE_RELN,SEQUENCE_RELN,"+")
+	inv = SST.InsertArrowDirectory(sst,"leadsto",SEQUENCE_RELN_INV,SEQUENCE_RELN_INV,"-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+	arr = SST.InsertArrowDirectory(sst,"properties","url","has URL","+")
+	inv = SST.InsertArrowDirectory(sst,"properties","isurl","is a URL for","-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+	arr = SST.InsertArrowDirectory(sst,"properties","img","has image","+")
+	inv = SST.InsertArrowDirectory(sst,"properties","isimg","is an image for","-")
+	SST.InsertInverseArrowDirectory(sst,arr,inv)
+
+}
+
+//**************************************************************
+
+func FindConfigFiles() []string {
+
+	return FindConfigFilesFrom(".")
+}
+
+//**************************************************************
+
+func FindConfigFilesFrom(dir string) []string {
+
+	// SST_CONFIG_PATH, or else the nearest SSTconfig directory
+	// looking up to two levels above dir, followed by the project's
+	// own arrows from SST_LOCAL_CONFIG_PATH or SSTconfig.local
+
+	path := os.Getenv("SST_CONFIG_PATH")
+
+	if path == "" {
+		path = FindDirFrom(dir,CONFIG_DIR)
+	}
+
+	if path == "" {
+		return []string{"no configuration file"}
+	}
+
+	return append(ConfigFilesIn(path),LocalConfigFilesFrom(dir)...)
+}
+
+//**************************************************************
+
+func LocalConfigFilesFrom(dir string) []string {
+
+	// A project's own arrows, to add to the shared configuration
+
+	local := os.Getenv("SST_LOCAL_CONFIG_PATH")
+
+	if local == "" {
+		local = FindDirFrom(dir,LOCAL_CONFIG_DIR)
+	}
+
+	if local == "" {
+		return nil
+	}
+
+	return SSTFilesIn(local,nil)
+}
+
+//**************************************************************
+
+func FindDirFrom(dir,name string) string {
+
+	search_paths := []string{"./"+name,"../"+name,"../../"+name}
+
+	for p := range search_paths {
+
+		path := search_paths[p]
+
+		if dir != "." {
+			path = dir+"/"+path
+		}
+
+		info, err := os.Stat(path);
+
+		if err == nil && info.IsDir() {
+			return path
+		}
+	}
+
+	return ""
+}
+
+//**************************************************************
+
+func ConfigFilesIn(dir string) []string {
+
+	// The shipped files first, in their usual order, even if they're
+	// missing so that we say so, then any others in alphabetical order
+
+	var configs []string
+
+	for f := 0; f < len(CONFIG_FILES); f++ {
+		configs = append(configs,dir+"/"+CONFIG_FILES[f])
+	}
+
+	return append(configs,SSTFilesIn(dir,CONFIG_FILES)...)
+}
+
+//**************************************************************
+
+func SSTFilesIn(dir string,except []string) []string {
+
+	files,_ := filepath.Glob(dir+"/*.sst")
+
+	sort.Strings(files)
+
+	var configs []string
+
+	for _,file := range files {
+
+		skip := false
+
+		for _,e := range except {
+			if filepath.Base(file) == e {
+				skip = true
+			}
+		}
+
+		if !skip {
+			configs = append(configs,file)
+		}
+	}
+
+	return configs
+}
+
+//**************************************************************
+
+func GetToken(p *Parser,src []rune, pos int) (string,int) {
+
+	// Handle concatenation of words/lines and separation of types
+
+	var token string
+
+	if pos >= len(src) {	    // end of file
+		return "", pos
+	}
+
+	switch (src[pos]) {
+
+	case '+':  // could be +::
+
+		switch (src[pos+1]) {
+
+		case ':':
+			token,pos = ReadToLast(p,src,pos,':')
+		default:
+			token,pos = ReadToLast(p,src,pos,ALPHATEXT)
+		}
+
+	case '-':  // could -:: or -section
+
+		switch (src[pos+1]) {
+
+		case ':':
+			token,pos = ReadToLast(p,src,pos,':')
+		default:
+			token,pos = ReadToLast(p,src,pos,ALPHATEXT)
+		}
+
+	case ':':
+		token,pos = ReadToLast(p,src,pos,':')
+
+	case '(':
+		token,pos = ReadToLast(p,src,pos,')')
+
+        case '"','\'':
+		quote := src[pos]
+
+		if IsQuote(quote) && IsBackReference(src,pos) {
+			token = "\""
+			pos++
+		} else {
+			if quote == '"' && pos+2 < len(src) && IsWhiteSpace(src[pos+1],src[pos+2]) {
+				ParseError(p,ERR_ILLEGAL_QUOTED_STRING_OR_REF,"")
+				p.Abandon = true
+				return "",pos
+			}
+			token,pos = ReadToLast(p,src,pos,quote)
+
+			if p.Abandon {
+				return "",pos
+			}
+
+			strip := strings.Split(token,string(quote))
+			token = strip[1]
+		}
+
+	case '#':
+		return "",pos
+
+	case '/':
+		if src[pos+1] == '/' {
+			return "",pos
+		}
+
+	case '@':
+		token,pos = ReadToLast(p,src,pos,' ')
+
+	default: // a text item that could end with any of the above
+		token,pos = ReadToLast(p,src,pos,ALPHATEXT)
+
+	}
+
+	return token, pos
+}
+
+//**************************************************************
+
+func ClassifyTokenRole(p *Parser,token string) {
+
+	if len(token) == 0 {
+		return
+	}
+
+	switch token[0] {
+
+	case ':':
+		expression := ExtractContextExpression(token)
+		RecordContext(p,expression)
+		CheckSequenceMode(p,expression,'+')
+		p.LineItemState = ROLE_CONTEXT
+		AssessGrammarCompletions(p,expression,p.LineItemState)
+
+	case '+':
+		expression := ExtractContextExpression(token)
+		RecordContext(p,expression)
+		CheckSequenceMode(p,expression,'+')
+		p.LineItemState = ROLE_CONTEXT_ADD
+		AssessGrammarCompletions(p,expression,p.LineItemState)
+
+	case '-':
+		if token[1:2] == string(':') {
+			expression := ExtractContextExpression(token)
+			RecordContext(p,expression)
+			CheckSequenceMode(p,expression,'-')
+			p.LineItemState = ROLE_CONTEXT_SUBTRACT
+			AssessGrammarCompletions(p,expression,p.LineItemState)
+		} else {
+			section := strings.TrimSpace(token[1:])
+			p.LineItemState = ROLE_SECTION
+			AssessGrammarCompletions(p,section,p.LineItemState)
+		}
+
+		// No quotes here in a string, we need to allow quoting in excerpts.
+
+	case '(':
+		if p.LineItemState == ROLE_RELATION {
+			ParseError(p,ERR_MISSING_ITEM_RELN,"")
+			p.Abandon = true
+			return
+		}
+		link := GetLinkArrowByName(p,token)
+		if p.Abandon {
+			return
+		}
+		p.LineItemState = ROLE_RELATION
+		p.LineRelnCache["THIS"] = append(p.LineRelnCache["THIS"],link)
+		p.LineRelnCounter++
+
+	case '"': // prior reference
+		result := LookupAlias(p,"PREV",p.LineItemCounter)
+		if p.Abandon {
+			return
+		}
+		p.LineItemCache["THIS"] = append(p.LineItemCache["THIS"],result)
+		StoreAlias(p,result)
+		AssessGrammarCompletions(p,result,p.LineItemState)
+		p.LineItemState = ROLE_EVENT
+		p.LineItemCounter++
+
+	case '@':
+		p.LineItemState = ROLE_LINE_ALIAS
+		token  = strings.TrimSpace(token)
+		p.LineAlias = token[1:]
+		CheckLineAlias(p,token)
+		if !p.Abandon {
+			p.Aliases = append(p.Aliases,Mention{Place: Here(p),Text: p.LineAlias})
+		}
+
+	case '$':
+		CheckLineAlias(p,token)
+		if p.Abandon {
+			return
+		}
+		actual := ResolveAliasedItem(p,token)
+		if p.Abandon {
+			return
+		}
+		p.LineItemCache["THIS"] = append(p.LineItemCache["THIS"],actual)
+		PVerbose(p,"fyi, line reference",token,"resolved to",actual)
+		AssessGrammarCompletions(p,actual,p.LineItemState)
+		p.LineItemState = ROLE_LOOKUP
+		p.LineItemCounter++
+
+	default:
+		p.LineItemCache["THIS"] = append(p.LineItemCache["THIS"],token)
+		StoreAlias(p,token)
+		AssessGrammarCompletions(p,token,p.LineItemState)
+
+		p.LineItemState = ROLE_EVENT
+		p.LineItemCounter++
+	}
+}
+
+//**************************************************************
+
+func AssessGrammarCompletions(p *Parser,token string, prior_state int) {
+
+	if len(token) == 0 {
+		return
+	}
+
+	this_item := token
+
+	switch prior_state {
+
+	case ROLE_RELATION:
+
+		if !CheckNonNegative(p,p.LineItemCounter-2) {
+			return
+		}
+		last_item := p.LineItemCache["THIS"][p.LineItemCounter-2]
+		last_reln := p.LineRelnCache["THIS"][p.LineRelnCounter-1]
+		last_iptr := p.LineItemRefs[p.LineItemCounter-2]
+		this_iptr := HandleNode(p,this_item)
+		IdempAddLink(p,last_item,last_iptr,last_reln,this_item,this_iptr)
+		CheckSection(p)
+
+	case ROLE_CONTEXT:
+		Box(p,"Reset context: ->",this_item)
+		ContextEval(p,this_item,"=")
+		CheckSection(p)
+
+	case ROLE_CONTEXT_ADD:
+		PVerbose(p,"Add to context:",this_item)
+		ContextEval(p,this_item,"+")
+		CheckSection(p)
+
+	case ROLE_CONTEXT_SUBTRACT:
+		PVerbose(p,"Remove from context:",this_item)
+		ContextEval(p,this_item,"-")
+		CheckSection(p)
+
+	case ROLE_SECTION:
+		Box(p,"Set chapter/section: ->",this_item)
+		CheckChapter(p,this_item)
+		RecordChapter(p,this_item)
+		p.SectionState = this_item
+
+	default:
+		CheckSection(p)
+
+		if NoteToSelf(p,token) {
+			ParseError(p,WARN_NOTE_TO_SELF," ("+token+")")
+		}
+
+		HandleNode(p,this_item)
+		LinkUpStorySequence(p,this_item)
+	}
+}
+
+//**************************************************************
+
+func CheckLineAlias(p *Parser,token string) {
+
+	var contig string
+	fmt.Sscanf(token,"%s",&contig)
+
+	if token[0] == '@' && len(contig) == 1 {
+		ParseError(p,ERR_BAD_LABEL_OR_REF,token)
+		p.Abandon = true
+	}
+}
+
+//**************************************************************
+
+func CheckChapter(p *Parser,name string) {
+
+	// Keep the chapter name even when it's wrong, so the rest can be checked
+
+	if name[0] == ':' {
+		ParseError(p,WARN_CHAPTER_CLASS_MIXUP,name)
+	} else if strings.Contains(name,",") {
+		ParseError(p,ERR_CHAPTER_COMMA,name)
+	}
+
+	p.SequenceMode = false
+	p.SequenceStart = false
+}
+
+//**************************************************************
+
+func StoreAlias(p *Parser,name string) {
+
+	if p.LineAlias != "" {
+		PVerbose(p,"-- Storing alias",p.LineItemCache[p.LineAlias],name,"as",p.LineAlias)
+		p.LineItemCache[p.LineAlias] = append(p.LineItemCache[p.LineAlias],name)
+	}
+}
+
+
+//**************************************************************
+// Splitting notes across files
+//**************************************************************
+
+func IsDirective(src []rune,pos int) bool {
+
+	// Only at the start of a line, and only these words, so that
+	// an item like "%age of voters" is still an item
+
+	for _,d := range []string{DIRECTIVE_INCLUDE,DIRECTIVE_EXPORT,DIRECTIVE_ARROWS,DIRECTIVE_END} {
+
+		end := pos+len(d)
+
+		if end <= len(src) && string(src[pos:end]) == d && (end == len(src) || unicode.IsSpace(src[end])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//**************************************************************
+
+func Directive(p *Parser,src []rune,pos int) int {
+
+	// The directive takes the rest of the line, apart from a comment
+
+	end := pos
+
+	for ; end < len(src) && src[end] != '\n'; end++ {
+	}
+
+	line := string(src[pos:end])
+	line = regexp.MustCompile(`\s+(#|//).*$`).ReplaceAllString(line,"")
+
+	fields := strings.SplitN(strings.TrimSpace(line)," ",2)
+	directive := strings.TrimSpace(fields[0])
+
+	var arg string
+
+	if len(fields) > 1 {
+		arg = strings.Trim(strings.TrimSpace(fields[1]),"\"'")
+	}
+
+	switch directive {
+
+	case DIRECTIVE_ARROWS:
+		return ArrowDefinitions(p,src,pos)
+
+	case DIRECTIVE_END:
+		ParseError(p,ERR_STRAY_END,"")
+		return end
+	}
+
+	if arg == "" {
+		ParseError(p,ERR_BAD_DIRECTIVE,directive)
+		return end
+	}
+
+	switch directive {
+
+	case DIRECTIVE_INCLUDE:
+		Include(p,arg)
+
+	case DIRECTIVE_EXPORT:
+		p.Exported = append(p.Exported,Export{strings.TrimPrefix(arg,"@"),pos})
+	}
+
+	return end
+}
+
+//**************************************************************
+
+func ArrowDefinitions(p *Parser,src []rune,pos int) int {
+
+	// Arrows for these notes, written as in SSTconfig between %arrows
+	// and %end. They are added to the graph for everyone, like the rest
+
+	from,to,end,found := ArrowBlock(src,pos)
+
+	if !found {
+		ParseError(p,ERR_UNTERMINATED_ARROWS,"")
+		return from
+	}
+
+	q := NewParser(p.Graph,p.File,p.Config)
+	q.Configuring = true
+
+	ParseConfigRange(q,src,from,to)
+
+	p.Diagnostics = append(p.Diagnostics,q.Diagnostics...)
+
+	// We skipped these lines, so count them for the page map
+
+	for i := pos; i < end; i++ {
+		if src[i] == '\n' {
+			p.LineNum++
+		}
+	}
+
+	return end
+}
+
+//**************************************************************
+
+func ArrowBlock(src []rune,pos int) (int,int,int,bool) {
+
+	// From the end of the %arrows line to the start of the %end line,
+	// and the end of that. Not found means no %end
+
+	from := pos
+
+	for ; from < len(src) && src[from] != '\n'; from++ {
+	}
+
+	for start := from+1; start < len(src); {
+
+		end := start
+
+		for ; end < len(src) && src[end] != '\n'; end++ {
+		}
+
+		line := strings.TrimSpace(string(src[start:end]))
+
+		if line == DIRECTIVE_END || strings.HasPrefix(line,DIRECTIVE_END+" ") || strings.HasPrefix(line,DIRECTIVE_END+"\t") {
+			return from,start,end,true
+		}
+
+		start = end+1
+	}
+
+	return from,from,from,false
+}
+
+//**************************************************************
+
+func Include(p *Parser,name string) {
+
+	// Parse another file into the same graph, here and now, with its
+	// own aliases and context, as if it were named on the command line
+
+	path := name
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.File),path)
+	}
+
+	abs,err := filepath.Abs(path)
+
+	if err != nil {
+		ParseError(p,ERR_NO_SUCH_FILE_FOUND,path)
+		return
+	}
+
+	self,_ := filepath.Abs(p.File)
+	chain := append(append([]string{},p.Including...),self)
+
+	for c := range chain {
+		if chain[c] == abs {
+			ParseError(p,ERR_INCLUDE_CYCLE,strings.Join(append(chain[c:],abs)," -> "))
+			return
+		}
+	}
+
+	if p.Graph.Files != nil {
+		if p.Graph.Files[abs] {
+			PVerbose(p,"Already read",path)
+			return
+		}
+		p.Graph.Files[abs] = true
+	}
+
+	text,err := ReadFile(path)
+
+	if err != nil {
+		ParseError(p,ERR_NO_SUCH_FILE_FOUND,path)
+		return
+	}
+
+	q := NewParser(p.Graph,path,p.Config)
+	q.Including = chain
+
+	ParseText(q,text)
+
+	p.Diagnostics = append(p.Diagnostics,q.Diagnostics...)
+
+	Box(p,"Back in",p.File)
+}
+
+//**************************************************************
+
+func ExportAliases(p *Parser) {
+
+	// At the end of the file, when the aliases have all their items
+
+	for _,e := range p.Exported {
+
+		p.Pos = e.Pos
+
+		items,ok := p.LineItemCache[e.Alias]
+
+		if !ok {
+			ParseError(p,ERR_NO_SUCH_EXPORT,e.Alias)
+			continue
+		}
+
+		if _,exists := p.Graph.Exports[e.Alias]; exists {
+			ParseError(p,WARN_EXPORT_REPLACED,e.Alias)
+		}
+
+		p.Graph.Exports[e.Alias] = items
+		p.AliasUsed[e.Alias] = true
+	}
+}
+
+//**************************************************************
+// Memory representation
+//**************************************************************
+
+func IdempAddLink(p *Parser,from string, frptr SST.NodePtr, link SST.Link,to string, toptr SST.NodePtr) {
+
+	// Add a link index cache pointer directly to a from node
+
+	sst := p.Graph.PoSST
+
+	if from == to {
+		ParseError(p,ERR_ARROW_SELFLOOP,"")
+		p.Abandon = true
+		return
+	}
+
+	if link.Wgt != 1 {
+		PVerbose(p,"... Relation:",from,"--(",sst.ArrowDirectory[link.Arr].Long,",",link.Wgt,")->",to,link.Ctx)
+	} else {
+		PVerbose(p,"... Relation:",from,"--",sst.ArrowDirectory[link.Arr].Long,"->",to,link.Ctx)
+	}
+
+        // Build PageMap
+
+	link.Dst = toptr
+	p.LinePath = append(p.LinePath,link)
+
+	if from == "" || to == "" {
+		ParseError(p,ERR_MISSING_ITEM_SOMEWHERE," (adding link)")
+		p.Abandon = true
+		return
+	}
+
+	SST.AppendLinkToNode(sst,frptr,link,toptr)
+
+	// Double up the reverse definition for easy indexing of both in/out arrows
+	// But be careful not the make the graph undirected by mistake
+
+	invlink := GetLinkArrowByName(p,sst.ArrowDirectory[sst.InverseArrows[link.Arr]].Short)
+
+	if p.Abandon {
+		return
+	}
+
+	SST.AppendLinkToNode(sst,toptr,invlink,frptr)
+
+	RecordLink(p,SourceLink{frptr,link.Arr,toptr})
+	RecordLink(p,SourceLink{toptr,invlink.Arr,frptr})
+}
+
+//**************************************************************
+
+func HandleNode(p *Parser,annotated string) SST.NodePtr {
+
+	clean_ptr,clean_version := IdempAddNode(p,annotated,SEQ_UNKNOWN)
+
+	PVerbose(p,"Event/item/node: \"",clean_version,"\" in chapter",p.SectionState)
+
+	p.LineItemRefs = append(p.LineItemRefs,clean_ptr)
+
+	if _,seen := p.Graph.Sources.Items[clean_ptr]; !seen && p.Graph.Sources.Items != nil {
+		p.Graph.Sources.Items[clean_ptr] = Here(p)
+	}
+
+	if len(clean_version) != len(annotated) {
+		AddBackAnnotations(p,clean_version,clean_ptr,annotated)
+	}
+
+	if !p.Config.Verbose && p.SignsOfLife {
+		if (p.SignOfLife % 1000) == 0 {
+			fmt.Print("+ ")
+		}
+		p.SignOfLife++
+	}
+
+	IdempAddContextToNode(p,clean_ptr)
+
+	return clean_ptr
+}
+
+//**************************************************************
+
+func IdempAddNode(p *Parser,s string,intended_sequence bool) (SST.NodePtr,string) {
+
+	clean_version := StripAnnotations(p,s)
+
+	l,c := SST.StorageClass(s)
+
+	var new_nodetext SST.Node
+	new_nodetext.S = clean_version
+	new_nodetext.L = l
+	new_nodetext.Seq = new_nodetext.Seq || intended_sequence
+	new_nodetext.Chap = p.SectionState
+	new_nodetext.NPtr.Class = c
+
+	iptr := SST.AppendTextToDirectory(p.Graph.PoSST,new_nodetext,func(message string) {
+		ParseMessage(p,message)
+	})
+
+	// Build page map
+
+	if p.LinePath == nil {
+		var leg SST.Link
+		leg.Dst = iptr
+		p.LinePath = append(p.LinePath,leg)
+	}
+
+	return iptr,clean_version
+}
+
+//**************************************************************
+
+func IdempAddContextToNode(p *Parser,nptr SST.NodePtr) {
+
+	// add a nullpotent link containing root node for
+	// context membership, in case it's a singleton
+
+	var nowhere SST.NodePtr
+	var empty SST.Link
+	empty.Ctx = SST.RegisterContext(p.Graph.PoSST,p.ContextState,nil)
+	empty.Arr = 0
+	empty.Wgt = 1
+
+	SST.AppendLinkToNode(p.Graph.PoSST,nptr,empty,nowhere)
+}
+
+//**************************************************************
+// Scan text input
+//**************************************************************
+
+func ReadFile(filename string) ([]rune,error) {
+
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil,err
+	}
+
+	defer file.Close()
+
+	return ReadRunes(file)
+}
+
+//**************************************************************
+
+func ReadRunes(r io.Reader) ([]rune,error) {
+
+	// Read a stream rune by rune and clean unicode nonsense
+
+	reader := bufio.NewReader(r)
+
+	var text []rune
+
+	for {
+		c,_,err := reader.ReadRune()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return text,err
+		}
+
+		switch c {
+		case NON_ASCII_LQUOTE,NON_ASCII_RQUOTE:
+			c = '"'
+		}
+
+		text = append(text,c)
+	}
+
+	return text,nil
+}
+
+//**************************************************************
+
+func ReadToLast(p *Parser,src []rune,pos int, stop rune) (string,int) {
+
+	// Read until we find a terminator for this kind of token
+	// determined by "stop" signal - watch out for embedded quotes
+
+	var cpy []rune
+
+	var starting_at = p.LineNum
+
+	// We have to read the string in rune form to handle unicode
+	// rune by rune to handle special cases and aggregated into cpy
+
+	for ; Collect(p,src,pos,stop,cpy) && pos < len(src); pos++ {
+
+		cpy = append(cpy,src[pos])
+
+		// if there's an embedded " quote, treat quoted section as a single character
+
+		if pos+1 < len(src) && src[pos] == '"' {
+			for q := pos+1; q < len(src); q++ {
+				cpy = append(cpy,src[q])
+				if src[q] == '"' {
+					pos = q
+					break
+				}
+			}
+		}
+	}
+
+	if p.Abandon {
+		return "",pos
+	}
+
+	if IsQuote(stop) && src[pos-1] != stop {
+		e := fmt.Sprintf(" starting at line %d (found token %s)",starting_at,string(cpy))
+		ParseError(p,ERR_MISMATCH_QUOTE,e)
+		p.Abandon = true
+		return "",pos
+	}
+
+	// Tokenize the string
+
+	token := string(cpy)
+	token = strings.TrimSpace(token)
+	count := strings.Count(token,"\n")
+	p.LineNum += count
+	return token,pos
+}
+
+//**************************************************************
+
+func Collect(p *Parser,src []rune,pos int, stop rune,cpy []rune) bool {
+
+	// Generalize the stop-condition for for-loop accumulating runes
+	// when we receive the "stop" rune signal, that's the end by policy
+
+	var collect bool = true
+
+	// Quoted strings are tricky, especially when they start in the middle of another string
+
+	if IsQuote(stop) {
+		var is_end bool
+
+		if pos+1 >= len(src) {
+			is_end= true
+		} else {
+			is_end = IsWhiteSpace(src[pos],src[pos+1])
+		}
+
+		if src[pos-1] == stop && is_end {
+			return false
+		} else {
+			return true
+		}
+	}
+
+	// nothing unquoted can exceed a line length
+
+	if pos >= len(src) || src[pos] == '\n' {
+		return false
+	}
+
+	// ordinary text strings are signalled by ALPHATEXT policy
+
+	if stop == ALPHATEXT {
+		collect = IsGeneralString(p,src,pos)
+	} else {
+		// a ::: cluster is special, we don't care how many
+
+		if stop != ':' && !IsQuote(stop) {
+			return !LastSpecialChar(src,pos,stop)
+		} else {
+			var groups int = 0
+
+			for r := 1; r < len(cpy)-1; r++ {
+
+				if cpy[r] != ':' && cpy[r-1] == ':' {
+					groups++
+				}
+
+				if cpy[r] != '"' && cpy[r-1] == '"' {
+					groups++
+				}
+			}
+
+			if groups > 1 {
+				collect = !LastSpecialChar(src,pos,stop)
+			}
+		}
+	}
+
+	return collect
+}
+
+//**************************************************************
+
+func IsGeneralString(p *Parser,src []rune,pos int) bool {
+
+	// Plain text should terminate like this, but
+	// beware of quotes inside
+
+	switch src[pos] {
+
+        case ')':
+		p.Pos = pos
+	        ParseError(p,ERR_STRAY_PAREN,"")
+		p.Abandon = true
+		return false
+	case '(':
+		return false
+	case '#':
+		return false
+	case '\n':
+		return false
+
+	case '/':
+		if src[pos+1] == '/' {
+			return false
+		}
+	}
+
+	return true
+}
+
+//**************************************************************
+
+func IsQuote(r rune) bool {
+
+	switch r {
+	case '"','\'',NON_ASCII_LQUOTE,NON_ASCII_RQUOTE:
+		return true
+	}
+
+	return false
+}
+
+//**************************************************************
+
+func LastSpecialChar(src []rune,pos int, stop rune) bool {
+
+	if src[pos] == '\n' {
+		if stop != '"' {
+			return true
+		}
+	}
+
+	// Special case, but still don't understand why?!
+
+	if src[pos] == '@' {
+		return false
+	}
+
+	if pos > 0 && src[pos-1] == stop && src[pos] != stop {
+		return true
+	}
+
+	return false
+}
+
+//**************************************************************
+
+func UpdateLastLineCache(p *Parser) {
+
+	if Dangler(p) {
+		ParseError(p,ERR_MISSING_EVENT,"")
+	}
+
+	if !p.Configuring {
+		PageMap(p,p.SectionState,p.ContextState,p.LinePath,p.LineNum,p.LineAlias)
+	}
+
+	p.LineNum++
+
+	// If this line was not blank, overwrite previous settings and reset
+
+	if p.LineItemState != ROLE_BLANK_LINE {
+
+		if p.LineItemCache["THIS"] != nil {
+			p.LineItemCache["PREV"] = p.LineItemCache["THIS"]
+		}
+		if p.LineRelnCache["THIS"] != nil {
+			p.LineRelnCache["PREV"] = p.LineRelnCache["THIS"]
+		}
+	}
+
+	p.LineItemCache["THIS"] = nil
+	p.LineRelnCache["THIS"] = nil
+	p.LineItemRefs = nil
+	p.LineItemCounter = 1
+	p.LineRelnCounter = 0
+	p.LineAlias = ""
+	p.LinePath = nil
+
+	p.LineItemState = ROLE_BLANK_LINE
+}
+
+//**************************************************************
+
+func PageMap(p *Parser,chapter string,ctxmap map[string]bool,path []SST.Link,line int,alias string) {
+
+	if len(path) == 0 {
+		return
+	}
+
+	var page_event SST.PageMap;
+
+	page_event.Chapter = chapter
+	page_event.Alias = alias
+	page_event.Context = SST.RegisterContext(p.Graph.PoSST,ctxmap,nil)
+	page_event.Line = line
+	page_event.Path = path
+
+	p.Graph.PageMap = append(p.Graph.PageMap,page_event)
+}
+
+//**************************************************************
+
+func IsWhiteSpace(r,rn rune) bool {
+
+	return (unicode.IsSpace(r) || r == '#' || r == '/' && rn == '/')
+}
+
+//**************************************************************
+
+func IsBackReference(src []rune,pos int) bool {
+
+	// Any non-whitespace before \n or ( means it's not a back reference
+
+	for pos++; pos < len(src); pos++ {
+
+		if src[pos] == '(' || src[pos] == '\n' || src[pos] == '#' {
+			return true
+		} else {
+			if !unicode.IsSpace(src[pos]) {
+				return false
+			}
+		}
+	}
+
+	return false
+}
+
+//**************************************************************
+
+func Dangler(p *Parser) bool {
+
+	switch p.LineItemState {
+
+	case ROLE_EVENT:
+		return false
+	case ROLE_LOOKUP:
+		return false
+	case ROLE_BLANK_LINE:
+		return false
+	case ROLE_SECTION:
+		return false
+	case ROLE_CONTEXT:
+		return false
+	case ROLE_CONTEXT_ADD:
+		return false
+	case ROLE_CONTEXT_SUBTRACT:
+		return false
+	case HAVE_MINUS:
+		return false
+	}
+
+	return true
+}
+
+//**************************************************************
+
+func ExtractContextExpression(token string) string {
+
+	var expression string
+
+	s := strings.Split(token, ":")
+
+	for i := 1; i < len(s); i++ {
+		if len(s[i]) > 1 {
+			expression = strings.TrimSpace(s[i])
+			break
+		}
+	}
+
+	return expression
+}
+
+//**************************************************************
+
+func CheckSequenceMode(p *Parser,context string, mode rune) {
+
+	if (strings.Contains(context,"_sequence_")) {
+
+		switch mode {
+		case '+':
+			PVerbose(p,"\nStart sequence mode for items")
+			p.SequenceMode = true
+			p.SequenceStart = true
+			p.LastInSequence = ""
+
+		case '-':
+			PVerbose(p,"End sequence mode for items\n")
+			p.SequenceMode = false
+			p.SequenceStart = false
+		}
+	}
+
+}
+
+//**************************************************************
+
+func LinkUpStorySequence(p *Parser,this string) {
+
+	// Join together a sequence of nodes using default "(then)"
+
+	sst := p.Graph.PoSST
+
+	if p.SequenceMode && this != p.LastInSequence {
+
+		if p.LineItemCounter == 1 && p.LastInSequence != "" {
+
+			PVerbose(p,"* ... Sequence addition: ",p.LastInSequence,"-(",SEQUENCE_RELN,")->",this,"\n")
+
+			var last_iptr SST.NodePtr
+
+			if p.SequenceStart {
+				last_iptr,_ = IdempAddNode(p,p.LastInSequence,SEQ_START)
+				p.SequenceStart = false
+			} else {
+				last_iptr,_ = IdempAddNode(p,p.LastInSequence,SEQ_UNKNOWN)
+			}
+
+			this_iptr,_ := IdempAddNode(p,this,SEQ_UNKNOWN)
+			link := GetLinkArrowByName(p,"(then)")
+			SST.AppendLinkToNode(sst,last_iptr,link,this_iptr)
+
+			invlink := GetLinkArrowByName(p,sst.ArrowDirectory[sst.InverseArrows[link.Arr]].Short)
+			SST.AppendLinkToNode(sst,this_iptr,invlink,last_iptr)
+
+		}
+
+		p.LastInSequence = this
+	}
+}
+
+//**************************************************************
+
+func StripAnnotations(p *Parser,fulltext string) string {
+
+	var protected bool = false
+	var deloused []rune
+	var preserve_unicode = []rune(fulltext)
+
+	for r := 0; r < len(preserve_unicode); r++ {
+
+		if preserve_unicode[r] == '"' {
+			protected = !protected
+		}
+
+		if !protected {
+			skip,symb := EmbeddedSymbol(p,preserve_unicode,r)
+			if skip > 0 {
+				r += skip-1
+				if unicode.IsSpace(preserve_unicode[r]) {
+					ParseError(p,ERR_NON_WORD_WHITE,symb)
+				}
+				continue
+			}
+		}
+
+		deloused = append(deloused,preserve_unicode[r])
+	}
+
+	return string(deloused)
+}
+
+//**************************************************************
+
+func AddBackAnnotations(p *Parser,cleantext string,cleanptr SST.NodePtr,annotated string) {
+
+	var protected bool = false
+
+	reminder := fmt.Sprintf("%.30s...",cleantext)
+	PVerbose(p,"\n        Checking annotations from \""+reminder+"\"")
+
+	for r := 0; r < len(annotated); r++ {
+
+		if annotated[r] == '"' {
+			protected = !protected
+		} else {
+			if !protected {
+				skip,symb := EmbeddedSymbol(p,[]rune(annotated),r)
+
+				if skip > 0 {
+					link := GetLinkArrowByName(p,p.Graph.Annotations[symb])
+
+					if p.Abandon {
+						return
+					}
+
+					this_item := ExtractWord(p,annotated,r+skip)
+
+					if len(this_item) <= WORD_MISTAKE_LEN {
+						err := fmt.Sprintf(" \"%s\"  after annotation %s, len %d",this_item,symb,skip)
+						ParseError(p,ERR_SHORT_WORD,err)
+					}
+
+					this_iptr,_ := IdempAddNode(p,this_item,SEQ_UNKNOWN)
+					IdempAddLink(p,reminder,cleanptr,link,this_item,this_iptr)
+
+					if p.Abandon {
+						return
+					}
+
+					r += skip-1
+					continue
+				}
+			}
+		}
+	}
+}
+
+//**************************************************************
+
+func EmbeddedSymbol(p *Parser,runetext []rune,offset int) (int,string) {
+
+	if offset >= len(runetext) {
+		return 0,"end of string"
+	}
+
+	var found_len int
+	var found string
+
+	for an := range p.Graph.Annotations {
+
+		// Careful of unicode, convert to runes
+
+		uni := []rune(an)
+		match := runetext[offset] == uni[0]
+
+		for r := 0; r < len(uni) && r+offset < len(runetext); r++ {
+
+			if uni[r] != runetext[offset+r] {
+				match = false
+				continue
+			}
+
+			if offset+r >= len(runetext)-1 {
+				match = false
+				continue
+			}
+
+			// No space between marker and text
+			if offset+r+1 < len(runetext) && unicode.IsSpace(runetext[offset+r+1]) {
+				match = false
+				continue
+			}
+		}
+
+		// There might still be another longer greedy match
+
+		if match && len(an) > found_len {
+			found = an
+			found_len = len(an)
+			match = false
+		}
+	}
+
+	if len(found) > 0 {
+		return found_len,found
+	}
+
+	return 0,"UNKNOWN SYMBOL"
+}
+
+//**************************************************************
+
+func ExtractWord(p *Parser,fulltext string,offset int) string {
+
+	var protected bool = false
+
+	runetext := []rune(fulltext)
+	var word []rune
+	var pair_quote string
+
+	for r := offset; r < len(runetext); r++ {
+
+		if runetext[r] == '"' || runetext[r] == '\'' {
+			protected = !protected
+			pair_quote = string(runetext[r]) + " "
+			continue
+		}
+
+		if !protected && !unicode.IsLetter(rune(runetext[r])) {
+
+			sword := strings.Trim(strings.TrimSpace(string(word)),pair_quote)
+			return sword
+		}
+
+		word = append(word,runetext[r])
+	}
+
+	sword := strings.Trim(strings.TrimSpace(string(word)),pair_quote)
+
+	if len(sword) <= WORD_MISTAKE_LEN {
+		ParseError(p,ERR_SHORT_WORD,"\""+sword+"\"")
+	}
+
+	return sword
+}
+
+//**************************************************************
+// Context logic
+//**************************************************************
+
+func ResetContextState(p *Parser) {
+
+	p.ContextState = make(map[string]bool)
+}
+
+//**************************************************************
+
+func ContextEval(p *Parser,s,op string) {
+
+	expr := CleanExpression(s)
+
+	or_parts := SplitWithParensIntact(expr,'|')
+
+	if strings.Contains(s,"(") {
+		ParseError(p,WARN_INADVISABLE_CONTEXT_EXPRESSION,"")
+	}
+
+	// +,-,= on p.ContextState
+
+	switch op {
+
+	case "=":
+		ResetContextState(p)
+		ModContext(p,or_parts,"+")
+	default:
+		ModContext(p,or_parts,op)
+	}
+}
+
+//**************************************************************
+
+func CleanExpression(s string) string {
+
+	s = TrimParen(s)
+	r1 := regexp.MustCompile("[|,]+")
+	s = r1.ReplaceAllString(s,"|")
+	r2 := regexp.MustCompile("[&]+")
+	s = r2.ReplaceAllString(s,".")
+	r3 := regexp.MustCompile("[.]+")
+	s = r3.ReplaceAllString(s,".")
+
+	return s
+}
+
+// ***********************************************************************
+
+func SplitWithParensIntact(expr string,split_ch rune) []string {
+
+	var token string = ""
+	var set []string
+
+	unicode := []rune(expr)
+
+	for c := 0; c < len(unicode); c++ {
+
+		switch unicode[c] {
+
+		case split_ch:
+			set = append(set,token)
+			token = ""
+
+		case '(':
+			subtoken,offset := Paren(unicode,c)
+			token += subtoken
+			c = offset-1
+
+		default:
+			token += string(unicode[c])
+		}
+	}
+
+	if len(token) > 0 {
+		set = append(set,token)
+	}
+
+	return set
+}
+
+// ***********************************************************************
+
+func Paren(s []rune, offset int) (string,int) {
+
+	var level int = 0
+
+	for c := offset; c < len(s); c++ {
+
+		if s[c] == '(' {
+			level++
+			continue
+		}
+
+		if s[c] == ')' {
+			level--
+			if level == 0 {
+				token := s[offset:c+1]
+				return string(token), c+1
+			}
+		}
+	}
+
+	return "bad expression", -1
+}
+
+// ***********************************************************************
+
+func TrimParen(s string) string {
+
+	var level int = 0
+	var trim = true
+
+	if len(s) == 0 {
+		return s
+	}
+
+	s = strings.TrimSpace(s)
+
+	if s[0] != '(' {
+		return s
+	}
+
+	for c := 0; c < len(s); c++ {
+
+		if s[c] == '(' {
+			level++
+			continue
+		}
+
+		if level == 0 && c < len(s)-1 {
+			trim = false
+		}
+
+		if s[c] == ')' {
+			level--
+
+			if level == 0 && c == len(s)-1 {
+
+				var token string
+
+				if trim {
+					token = s[1:len(s)-1]
+				} else {
+					token = s
+				}
+				return token
+			}
+		}
+	}
+
+	return s
+}
+
+//**************************************************************
+
+func ModContext(p *Parser,list []string,op string) {
+
+	for or_frag := range list {
+
+		frag := strings.TrimSpace(list[or_frag])
+
+		if len(frag) == 0 {
+			continue
+		}
+
+		switch op {
+		case "+":
+			p.ContextState[frag] = true
+
+		case "-": // to remove, we also need to look at children
+			for cand := range p.ContextState {
+				and_parts := SplitWithParensIntact(cand,'.')
+
+				for part := range and_parts {
+
+					if strings.Contains(and_parts[part],frag) {
+						delete(p.ContextState,cand)
+					}
+				}
+			}
+		}
+
+	}
+}
+
+//**************************************************************
+
+func CheckNonNegative(p *Parser,i int) bool {
+
+	if i < 0 {
+		ParseError(p,ERR_MISSING_ITEM_SOMEWHERE,"")
+		p.Abandon = true
+		return false
+	}
+
+	return true
+}
+
+//**************************************************************
+
+func CheckSection(p *Parser) {
+
+	// Once is enough, everything that follows would say the same
+
+	if len(p.SectionState) == 0 && !p.NoSection {
+		ParseError(p,ERR_MISSING_SECTION,"")
+		p.NoSection = true
+	}
+}
+
+//**************************************************************
+
+func NoteToSelf(p *Parser,s string) bool {
+
+	if len(s) <= 2 * WORD_MISTAKE_LEN {
+		return false
+	}
+
+	const intentionality_threshold = 50
+
+	if (len(s) > intentionality_threshold) && s[len(s)-1] == '.' {
+		return false
+	}
+
+	for _, r := range s {
+
+		if !unicode.IsUpper(r) && (unicode.IsLetter(r) || unicode.IsNumber(r)) {
+			return false
+		}
+	}
+
+	// Don't repeat the same message for multi-line dittos
+
+	if p.LineItemCache["THIS"] != nil && p.LineItemCache["PREV"] != nil {
+		if p.LineItemCache["THIS"][0] == p.LineItemCache["PREV"][0] {
+			return false
+		}
+	}
+
+	return true
+}
+
+//**************************************************************
+
+func StripParen(token string) string {
+
+	token =	strings.TrimSpace(token[1:])
+
+	if token[0] == '(' {
+		token =	strings.TrimSpace(token[1:])
+	}
+
+	if token[len(token)-1] == ')' {
+		token =	token[:len(token)-1]
+	}
+
+	return token
+}
+
+//**************************************************************
+// Lint, for N4L -lint
+//**************************************************************
+
+const (
+	LINT_CONTEXT_TERMS = 6   // more alternatives than this in one :: context ::
+	LINT_CONTEXT_LENGTH = 80 // or longer than this, is hard to search for
+
+	WARN_UNUSED_ALIAS = "WARNING: Alias is never referred to as $alias.n: "
+	WARN_UNUSED_ARROW = "WARNING: Arrow is defined but never used: "
+	WARN_ISOLATED_ITEM = "WARNING: Item has no links to anything else: "
+	WARN_CONTRADICTORY_ARROWS = "WARNING: Contradictory arrows between the same two items: "
+	WARN_LONG_CONTEXT = "WARNING: Suspiciously long context expression - split it up? "
+
+	ERR_NO_SUCH_LINT_RULE = "No such lint rule: "
+)
+
+//**************************************************************
+
+type LintRule struct {
+
+	Name    string
+	Code    string
+	Doc     string
+	Default bool // on unless switched off
+}
+
+//**************************************************************
+
+var LINT_RULES = []LintRule{
+
+	{"caps","WARN_DIFFERENT_CAPITALS","items that differ only in capitalization",true},
+	{"unused-alias","WARN_UNUSED_ALIAS","@aliases never referred to as $alias.n",true},
+	{"unused-arrow","WARN_UNUSED_ARROW","arrows in the configuration that no notes use",false},
+	{"isolated","WARN_ISOLATED_ITEM","items with no links to anything else",true},
+	{"contradiction","WARN_CONTRADICTORY_ARROWS","e.g. (results in) and (did not result in) between the same items",true},
+	{"long-context","WARN_LONG_CONTEXT","context expressions too long to be useful in searches",true},
+}
+
+// Arrows, by long name, that can't both be true of the same two items
+
+var LINT_CONTRADICTIONS = [][2]string{
+
+	{"results in","did not result in"},
+	{"affects","doesn't affect"},
+	{"has a part","has no part"},
+	{"contains","does not contain"},
+	{"has example","has no example"},
+	{"equals","is not the same as"},
+	{"equals","not the same as"},
+	{"same as","is not the same as"},
+	{"same as","not the same as"},
+	{"similar to","is nothing like"},
+}
+
+//**************************************************************
+
+func LintSelect(spec string) (map[string]bool,error) {
+
+	// A comma separated list of rule names, e.g. "caps,isolated" for just
+	// those, or "-isolated,+unused-arrow" to change the defaults. "all"
+	// switches everything on
+
+	rules := make(map[string]bool)
+
+	terms := strings.Split(spec,",")
+
+	if spec == "" || strings.HasPrefix(terms[0],"-") || strings.HasPrefix(terms[0],"+") {
+		for _,r := range LINT_RULES {
+			rules[r.Name] = r.Default
+		}
+	}
+
+	if spec == "" {
+		return rules,nil
+	}
+
+	for _,term := range terms {
+
+		term = strings.TrimSpace(term)
+		on := !strings.HasPrefix(term,"-")
+		name := strings.TrimLeft(term,"+-")
+
+		if name == "all" {
+			for _,r := range LINT_RULES {
+				rules[r.Name] = on
+			}
+			continue
+		}
+
+		known := false
+
+		for _,r := range LINT_RULES {
+			if r.Name == name {
+				known = true
+			}
+		}
+
+		if !known {
+			return nil,errors.New(ERR_NO_SUCH_LINT_RULE+name)
+		}
+
+		rules[name] = on
+	}
+
+	return rules,nil
+}
+
+//**************************************************************
+
+func Lint(g *Graph,diags []Diagnostic,rules map[string]bool) []Diagnostic {
+
+	// Look over a parsed graph for things that are legal but probably
+	// not meant. Returns diags together with what was found, in order.
+	// The parser's own capitalization warnings are replaced by the
+	// caps rule, which says where the other spelling is
+
+	var out []Diagnostic
+
+	for _,d := range diags {
+		if d.Code != "WARN_DIFFERENT_CAPITALS" {
+			out = append(out,d)
+		}
+	}
+
+	if rules["caps"] {
+		out = append(out,LintCapitals(g)...)
+	}
+
+	if rules["unused-alias"] {
+		for _,a := range g.Sources.Aliases {
+			if !a.Used {
+				out = append(out,LintDiagnostic(a.Place,WARN_UNUSED_ALIAS,"@"+a.Text))
+			}
+		}
+	}
+
+	if rules["unused-arrow"] {
+		out = append(out,LintUnusedArrows(g)...)
+	}
+
+	if rules["isolated"] {
+		out = append(out,LintIsolated(g)...)
+	}
+
+	if rules["contradiction"] {
+		out = append(out,LintContradictions(g)...)
+	}
+
+	if rules["long-context"] {
+		for _,c := range g.Sources.Contexts {
+			if LintContextTerms(c.Text) > LINT_CONTEXT_TERMS || utf8.RuneCountInString(c.Text) > LINT_CONTEXT_LENGTH {
+				out = append(out,LintDiagnostic(c.Place,WARN_LONG_CONTEXT,"("+c.Text+")"))
+			}
+		}
+	}
+
+	SortDiagnostics(out)
+
+	return out
+}
+
+//**************************************************************
+
+func LintCapitals(g *Graph) []Diagnostic {
+
+	// Report each spelling after the first one written
+
+	var out []Diagnostic
+
+	first := make(map[string]SST.Node)
+
+	nodes := SST.GetMemoryNodes(g.PoSST)
+
+	sort.SliceStable(nodes,func(i,j int) bool {
+		return LintBefore(g.Sources.Items[nodes[i].NPtr],g.Sources.Items[nodes[j].NPtr])
+	})
+
+	for _,n := range nodes {
+
+		key := strings.ToLower(n.S)
+		other,seen := first[key]
+
+		if !seen {
+			first[key] = n
+			continue
+		}
+
+		where := g.Sources.Items[other.NPtr]
+		detail := fmt.Sprintf("(%s, also %s at %s:%d)",n.S,other.S,where.File,where.Line)
+		out = append(out,LintDiagnostic(g.Sources.Items[n.NPtr],SST.WARN_DIFFERENT_CAPITALS," "+detail))
+	}
+
+	return out
+}
+
+//**************************************************************
+
+func LintUnusedArrows(g *Graph) []Diagnostic {
+
+	// An arrow and its inverse are used together, so report the pair once
+
+	used := make(map[SST.ArrowPtr]bool)
+
+	for _,n := range SST.GetMemoryNodes(g.PoSST) {
+		for st := range n.I {
+			for _,l := range n.I[st] {
+				used[l.Arr] = true
+			}
+		}
+	}
+
+	var out []Diagnostic
+
+	for arr,where := range g.Sources.Arrows {
+
+		inverse,has_inverse := g.InverseArrows[arr]
+
+		if used[arr] || (has_inverse && (used[inverse] || inverse < arr)) {
+			continue
+		}
+
+		a := g.ArrowDirectory[arr]
+		out = append(out,LintDiagnostic(where,WARN_UNUSED_ARROW,a.Long+" ("+a.Short+")"))
+	}
+
+	return out
+}
+
+//**************************************************************
+
+func LintIsolated(g *Graph) []Diagnostic {
+
+	// Every item has an empty link to say which context it's in,
+	// anything else connects it
+
+	var out []Diagnostic
+
+	for _,n := range SST.GetMemoryNodes(g.PoSST) {
+
+		linked := false
+
+		for st := range n.I {
+			for _,l := range n.I[st] {
+				if l.Arr != 0 {
+					linked = true
+				}
+			}
+		}
+
+		where,known := g.Sources.Items[n.NPtr]
+
+		if !linked && known {
+			out = append(out,LintDiagnostic(where,WARN_ISOLATED_ITEM,"\""+n.S+"\""))
+		}
+	}
+
+	return out
+}
+
+//**************************************************************
+
+func LintContradictions(g *Graph) []Diagnostic {
+
+	// Both directions are stored, so it's enough to look at links
+	// going out of each item
+
+	var out []Diagnostic
+
+	reported := make(map[string]bool)
+
+	for _,n := range SST.GetMemoryNodes(g.PoSST) {
+
+		arrows := make(map[SST.NodePtr]map[SST.ArrowPtr]bool)
+
+		for st := range n.I {
+			for _,l := range n.I[st] {
+				if arrows[l.Dst] == nil {
+					arrows[l.Dst] = make(map[SST.ArrowPtr]bool)
+				}
+				arrows[l.Dst][l.Arr] = true
+			}
+		}
+
+		for dst,set := range arrows {
+			for _,pair := range LINT_CONTRADICTIONS {
+
+				yes,ok1 := g.ArrowLongDir[pair[0]]
+				no,ok2 := g.ArrowLongDir[pair[1]]
+
+				if !ok1 || !ok2 || !set[yes] || !set[no] {
+					continue
+				}
+
+				// Symmetric arrows turn up at both ends
+
+				key := fmt.Sprint(pair,n.NPtr,dst)
+
+				if n.NPtr.Class > dst.Class || (n.NPtr.Class == dst.Class && n.NPtr.CPtr > dst.CPtr) {
+					key = fmt.Sprint(pair,dst,n.NPtr)
+				}
+
+				if reported[key] {
+					continue
+				}
+
+				reported[key] = true
+
+				// Complain where the second one was written
+
+				where := g.Sources.Links[SourceLink{n.NPtr,yes,dst}]
+				later := g.Sources.Links[SourceLink{n.NPtr,no,dst}]
+
+				if LintBefore(where,later) {
+					where = later
+				}
+
+				other := SST.GetMemoryNodeFromPtr(g.PoSST,dst)
+				detail := fmt.Sprintf("\"%s\" (%s) and (%s) \"%s\"",n.S,pair[0],pair[1],other.S)
+				out = append(out,LintDiagnostic(where,WARN_CONTRADICTORY_ARROWS,detail))
+			}
+		}
+	}
+
+	return out
+}
+
+//**************************************************************
+
+func LintContextTerms(expression string) int {
+
+	var count int
+
+	for _,or := range SplitWithParensIntact(CleanExpression(expression),'|') {
+		count += len(strings.Split(TrimParen(or),"."))
+	}
+
+	return count
+}
+
+//**************************************************************
+
+func LintBefore(a,b Place) bool {
+
+	if a.File != b.File {
+		return a.File < b.File
+	}
+
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+
+	return a.Column < b.Column
+}
+
+//**************************************************************
+
+func LintDiagnostic(where Place,message,detail string) Diagnostic {
+
+	code := DIAGNOSTIC_CODES[message]
+
+	return Diagnostic{where.File,where.Line,where.Column,code.Severity,code.Code,message+detail}
+}
+
+//**************************************************************
+
+func RecordArrow(p *Parser,arr SST.ArrowPtr) {
+
+	if arr < 0 || p.Graph.Sources.Arrows == nil {
+		return
+	}
+
+	if _,seen := p.Graph.Sources.Arrows[arr]; !seen {
+		p.Graph.Sources.Arrows[arr] = Here(p)
+	}
+}
+
+//**************************************************************
+
+func RecordLink(p *Parser,l SourceLink) {
+
+	if _,seen := p.Graph.Sources.Links[l]; !seen && p.Graph.Sources.Links != nil {
+		p.Graph.Sources.Links[l] = Here(p)
+	}
+}
+
+//**************************************************************
+
+func RecordContext(p *Parser,expression string) {
+
+	p.Graph.Sources.Contexts = append(p.Graph.Sources.Contexts,Mention{Place: Here(p),Text: strings.TrimSpace(expression)})
+}
+
+//**************************************************************
+
+func RecordChapter(p *Parser,name string) {
+
+	p.Graph.Sources.Chapters = append(p.Graph.Sources.Chapters,Mention{Place: Here(p),Text: name})
+}
+
+//**************************************************************
+
+func Here(p *Parser) Place {
+
+	// Like Position(), but carrying on from the last call, since
+	// this is asked for every item in the file
+
+	if p.Pos < p.HerePos {
+		p.HerePos,p.HereLine,p.HereCol = 0,1,1
+	}
+
+	for ; p.HerePos < p.Pos && p.HerePos < len(p.Src); p.HerePos++ {
+		if p.Src[p.HerePos] == '\n' {
+			p.HereLine++
+			p.HereCol = 1
+		} else {
+			p.HereCol++
+		}
+	}
+
+	return Place{p.File,p.HereLine,p.HereCol}
+}
+
+//**************************************************************
+// Canonical formatting, for n4lfmt
+//**************************************************************
+
+const (
+	FORMAT_ARROWS_AS_IS = ""
+	FORMAT_ARROWS_SHORT = "short"
+	FORMAT_ARROWS_LONG = "long"
+
+	FORMAT_BLANK = 0
+	FORMAT_HEADER = 1   // -chapter or :: context ::
+	FORMAT_ITEMS = 2
+	FORMAT_COMMENT = 3
+	FORMAT_DIRECTIVE = 4 // %include, %export, %arrows or %end
+	FORMAT_VERBATIM = 5  // arrow definitions, left as they are
+
+	FORMAT_ITEM_INDENT = " "
+	FORMAT_DITTO_SHIFT = 2 // a " sits this far right of the item it repeats
+
+	ERR_FORMAT_CHANGES_GRAPH = "Formatting would change the graph, so the file was left alone"
+)
+
+var ErrFormatChangesGraph = errors.New(ERR_FORMAT_CHANGES_GRAPH)
+
+//**************************************************************
+
+type FormatLine struct {
+
+	Kind    int
+	Tokens  []string
+	Comment string
+	Indent  int  // original indentation, for comment lines
+}
+
+//**************************************************************
+
+func FormatChecked(src []rune,filename string,arrows string,cfg Config) (string,[]Diagnostic,error) {
+
+	// Format a file, but only if it parses without errors, and check
+	// that the result still means the same
+
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		src = append(src,'\n') // or the last line is missing from the page map
+	}
+
+	before,diags := NewGraph(SST.OpenMemory(),cfg)
+	diags = append(diags,ParseInto(before,strings.NewReader(string(src)),filename,cfg)...)
+
+	if HasErrors(diags) {
+		return "",diags,nil
+	}
+
+	text := Format(before,src,arrows)
+
+	after,_ := NewGraph(SST.OpenMemory(),cfg)
+	ParseInto(after,strings.NewReader(text),filename,cfg)
+
+	if FormatSignature(before) != FormatSignature(after) {
+		return "",diags,ErrFormatChangesGraph
+	}
+
+	return text,diags,nil
+}
+
+//**************************************************************
+
+func FormatSignature(g *Graph) string {
+
+	// Everything the notes produced, apart from line numbers
+
+	sst := g.PoSST
+
+	var out strings.Builder
+
+	for _,n := range SST.GetMemoryNodes(sst) {
+		fmt.Fprintln(&out,n.NPtr,n.S,n.Chap,n.Seq,n.I)
+	}
+
+	for c := range sst.ContextDirectory {
+		fmt.Fprintln(&out,sst.ContextDirectory[c])
+	}
+
+	for _,p := range sst.PageMap {
+		fmt.Fprintln(&out,p.Chapter,p.Alias,p.Context,p.Path)
+	}
+
+	return out.String()
+}
+
+//**************************************************************
+
+func Format(g *Graph,src []rune,arrows string) string {
+
+	// Re-emit notes that parse cleanly in a canonical layout. Only the
+	// whitespace and the spelling of arrows change, so the graph is the
+	// same, except for the line numbers in the page map
+
+	lines := FormatScan(g,src)
+
+	var out []string
+	var item_col int
+	var last_kind int = FORMAT_BLANK
+
+	for l := range lines {
+
+		line := lines[l]
+
+		if line.Kind == FORMAT_VERBATIM {
+			out = append(out,line.Tokens[0])
+			last_kind = line.Kind
+			continue
+		}
+
+		if line.Kind == FORMAT_BLANK {
+			if last_kind != FORMAT_BLANK {
+				out = append(out,"")
+				last_kind = FORMAT_BLANK
+			}
+			continue
+		}
+
+		// Headers stand apart from the items around them
+
+		if last_kind != FORMAT_BLANK && last_kind != FORMAT_COMMENT && (line.Kind == FORMAT_HEADER || last_kind == FORMAT_HEADER) {
+			if line.Kind != FORMAT_COMMENT {
+				out = append(out,"")
+			}
+		}
+
+		var text string
+
+		switch line.Kind {
+
+		case FORMAT_COMMENT:
+			if line.Indent > 0 {
+				text = FORMAT_ITEM_INDENT
+			}
+
+		case FORMAT_ITEMS:
+			if line.Tokens[0] == "\"" {
+				text = strings.Repeat(" ",item_col+FORMAT_DITTO_SHIFT)
+			} else {
+				text = FORMAT_ITEM_INDENT
+				item_col = len(FORMAT_ITEM_INDENT)
+
+				if line.Tokens[0][0] == '@' && len(line.Tokens) > 1 {
+					item_col += utf8.RuneCountInString(line.Tokens[0])+1
+				}
+			}
+		}
+
+		for t := range line.Tokens {
+
+			token := line.Tokens[t]
+
+			if token[0] == '(' {
+				token = FormatArrow(g.PoSST,token,arrows)
+			}
+
+			if t > 0 {
+				text += " "
+			}
+
+			text += token
+		}
+
+		if line.Comment != "" {
+			if len(line.Tokens) > 0 {
+				text += " "
+			}
+			text += line.Comment
+		}
+
+		out = append(out,text)
+		last_kind = line.Kind
+	}
+
+	// No blank lines at the ends
+
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+
+	return strings.Join(out,"\n")+"\n"
+}
+
+//**************************************************************
+
+func FormatScan(g *Graph,src []rune) []FormatLine {
+
+	// Split the source into lines of raw tokens, using the parser's own
+	// tokenizer, so we know exactly what belongs to what
+
+	p := NewParser(g,"",Config{})
+
+	var lines []FormatLine
+	var line FormatLine
+	var indent int = 0
+	var start_of_line bool = true
+	var verbatim_to int = 0
+
+	for pos := 0; pos < len(src); {
+
+		// Whitespace and comments, as in SkipWhiteSpace()
+
+		if IsWhiteSpace(src[pos],src[pos]) {
+
+			switch {
+
+			case src[pos] == '\n':
+				lines = append(lines,FormatClose(line))
+				line = FormatLine{}
+				indent = 0
+				start_of_line = true
+				pos++
+
+				if pos < verbatim_to {
+					block := strings.Split(string(src[pos:verbatim_to]),"\n")
+
+					for _,text := range block[:len(block)-1] {
+						lines = append(lines,FormatLine{Kind: FORMAT_VERBATIM,Tokens: []string{strings.TrimRightFunc(text,unicode.IsSpace)}})
+					}
+
+					pos = verbatim_to
+				}
+
+			case src[pos] == '#' || (src[pos] == '/' && pos+1 < len(src) && src[pos+1] == '/'):
+				end := pos
+
+				for ; end < len(src) && src[end] != '\n'; end++ {
+				}
+
+				line.Comment = strings.TrimRightFunc(string(src[pos:end]),unicode.IsSpace)
+
+				if start_of_line {
+					line.Indent = indent
+				}
+				pos = end
+
+			default:
+				if start_of_line {
+					indent++
+				}
+				pos++
+			}
+
+			continue
+		}
+
+		// A directive is the rest of the line, up to any comment
+
+		if start_of_line && IsDirective(src,pos) {
+
+			end := pos
+
+			for ; end < len(src) && src[end] != '\n'; end++ {
+			}
+
+			text := string(src[pos:end])
+
+			if loc := regexp.MustCompile(`\s+(#|//)`).FindStringIndex(text); loc != nil {
+				text = text[:loc[0]]
+			}
+
+			line.Tokens = append(line.Tokens,strings.Join(strings.Fields(text)," "))
+
+			if line.Tokens[0] == DIRECTIVE_ARROWS {
+				if _,to,_,found := ArrowBlock(src,pos); found {
+					verbatim_to = to
+				}
+			}
+
+			pos += utf8.RuneCountInString(text)
+			start_of_line = false
+			continue
+		}
+
+		start_of_line = false
+
+		start := pos
+		_,pos = GetToken(p,src,pos)
+
+		if pos == start {
+			pos++ // can't happen in a file that parses, but never loop
+			continue
+		}
+
+		raw := strings.TrimSpace(string(src[start:pos]))
+
+		line.Tokens = append(line.Tokens,raw)
+	}
+
+	lines = append(lines,FormatClose(line))
+
+	return lines
+}
+
+//**************************************************************
+
+func FormatClose(line FormatLine) FormatLine {
+
+	// Decide what kind of line this is, and tidy up headers
+
+	if len(line.Tokens) == 0 {
+		if line.Comment == "" {
+			line.Kind = FORMAT_BLANK
+		} else {
+			line.Kind = FORMAT_COMMENT
+		}
+		return line
+	}
+
+	first := line.Tokens[0]
+
+	switch {
+
+	case IsDirective([]rune(first),0):
+		line.Kind = FORMAT_DIRECTIVE
+
+	case strings.HasPrefix(first,"::") || strings.HasPrefix(first,"+:") || strings.HasPrefix(first,"-:"):
+		line.Kind = FORMAT_HEADER
+		line.Tokens[0] = FormatContext(first)
+
+	case first[0] == '-':
+		line.Kind = FORMAT_HEADER
+		line.Tokens[0] = "-"+strings.TrimSpace(first[1:])
+
+	default:
+		line.Kind = FORMAT_ITEMS
+	}
+
+	return line
+}
+
+//**************************************************************
+
+func FormatContext(token string) string {
+
+	// +::  a,b  :: becomes +:: a, b ::
+
+	var prefix string
+
+	if token[0] == '+' || token[0] == '-' {
+		prefix = token[:1]
+		token = token[1:]
+	}
+
+	expr := strings.TrimSpace(strings.Trim(token,":"))
+
+	parts := strings.Split(expr,",")
+
+	for p := range parts {
+		parts[p] = strings.TrimSpace(parts[p])
+	}
+
+	return prefix+":: "+strings.Join(parts,", ")+" ::"
+}
+
+//**************************************************************
+
+func FormatArrow(sst SST.PoSST,token string,arrows string) string {
+
+	// Only the name is ours to change, the rest (weight, context) is
+	// kept as written because spaces there change its meaning
+
+	inner := strings.TrimSpace(token[1:len(token)-1])
+
+	name := inner
+	var rest string
+
+	if c := strings.Index(inner,","); c >= 0 {
+		name = inner[:c]
+		rest = inner[c:]
+	}
+
+	ptr,ok := sst.ArrowShortDir[name]
+
+	if !ok {
+		ptr,ok = sst.ArrowLongDir[name]
+	}
+
+	if ok {
+		switch arrows {
+
+		case FORMAT_ARROWS_SHORT:
+			name = sst.ArrowDirectory[ptr].Short
+
+		case FORMAT_ARROWS_LONG:
+			long := sst.ArrowDirectory[ptr].Long
+
+			// Short names are looked up first, so don't rename it to another arrow's short name
+
+			other,clash := sst.ArrowShortDir[long]
+
+			if !clash || other == ptr {
+				name = long
+			}
+		}
+	}
+
+	return "("+name+rest+")"
+}
+
+//**************************************************************
+// Diagnostics and logging
+//**************************************************************
+
+func ParseError(p *Parser,message string,detail string) {
+
+	// Record a problem at the current token. message is one of the
+	// ERR_/WARN_ constants, which gives the code, detail says where
+
+	var d Diagnostic
+
+	code,known := DIAGNOSTIC_CODES[message]
+
+	if !known {
+		code = DiagnosticCode{"ERR_UNKNOWN",SEVERITY_ERROR}
+	}
+
+	d.File = p.File
+	d.Line,d.Column = Position(p.Src,p.Pos)
+	d.Severity = code.Severity
+	d.Code = code.Code
+	d.Message = message+detail
+
+	p.Diagnostics = append(p.Diagnostics,d)
+
+	if p.Config.Report != nil {
+		p.Config.Report(d)
+	}
+
+	Diag(p,"N4L",p.File,d.Message,"at line",d.Line)
+}
+
+//**************************************************************
+
+func ParseMessage(p *Parser,message string) {
+
+	// A message from the SST library, e.g. through AppendTextToDirectory(),
+	// may carry some detail after the constant - take the longest match
+
+	var found string
+
+	for known := range DIAGNOSTIC_CODES {
+		if strings.HasPrefix(message,known) && len(known) > len(found) {
+			found = known
+		}
+	}
+
+	if found == "" {
+		ParseError(p,message,"")
+		return
+	}
+
+	ParseError(p,found,strings.TrimPrefix(message,found))
+}
+
+//**************************************************************
+
+func Position(src []rune,pos int) (int,int) {
+
+	// Line and column, counting from 1, of a rune offset
+
+	line,column := 1,1
+
+	for i := 0; i < pos && i < len(src); i++ {
+		if src[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	return line,column
+}
+
+//**************************************************************
+
+func FormatDiagnostic(d Diagnostic) string {
+
+	// file:line:column: severity CODE: message
+
+	severity := "error"
+
+	if d.Severity == SEVERITY_WARNING {
+		severity = "warning"
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s %s: %s",d.File,d.Line,d.Column,severity,d.Code,d.Message)
+}
+
+//**************************************************************
+
+func SortDiagnostics(diags []Diagnostic) {
+
+	sort.SliceStable(diags,func(i,j int) bool {
+		if diags[i].File != diags[j].File {
+			return diags[i].File < diags[j].File
+		}
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+}
+
+//**************************************************************
+
+func PVerbose(p *Parser,a ...interface{}) {
+
+	const green = "\x1b[36m"
+	const endgreen = "\x1b[0m"
+
+	if p.Config.Verbose {
+		fmt.Print(p.LineNum,":\t",green)
+		fmt.Println(a...)
+		fmt.Print(endgreen)
+	}
+}
+
+//**************************************************************
+
+func Box(p *Parser,a ...interface{}) {
+
+	if p.Config.Verbose {
+
+		fmt.Println("\n------------------------------------")
+		fmt.Println(a...)
+		fmt.Println("------------------------------------")
+		fmt.Println()
+	}
+}
+
+//**************************************************************
+
+func DiagnosticName(filename string) string {
+
+	return "test_output/"+filename+"_test_log"
+
+}
+
+//**************************************************************
+
+func Diag(p *Parser,a ...interface{}) {
+
+	// Log diagnostic output for self-diagnostic tests
+
+	if p.Config.Diagnostic {
+		s := fmt.Sprintln(a...)
+		prefix := fmt.Sprint(p.LineNum,":")
+		AppendStringToFile(p.DiagFile,prefix+s)
+	}
+}
+
+//**************************************************************
+
+func AppendStringToFile(name string, s string) {
+
+	// strip out \r that mess up the file format but are useful for term
+
+	san := strings.Replace(s,"\r","",-1)
+
+	f, err := os.OpenFile(name,os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		fmt.Println("Couldn't open for write/append to",name,err)
+		f.Close()
+		return
+	}
+
+	_, err = f.WriteString(san)
+
+	if err != nil {
+		fmt.Println("Couldn't write/append to",name,err)
+	}
+
+	f.Close()
+}
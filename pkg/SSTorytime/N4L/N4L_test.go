@@ -0,0 +1,104 @@
+package N4L
+
+import (
+	"strings"
+	"testing"
+
+        SST "SSTorytime"
+)
+
+// **************************************************************************
+
+func TestParseRecovers(t *testing.T) {
+
+	// Only the mandatory arrows, so (nosuch) is unknown
+
+	src := "-notes\n\n a (then) b\n c (nosuch) d\n e (then) e\n f (then) g\n"
+
+	g,diags := Parse(strings.NewReader(src),"test.n4l",Config{ConfigFiles: []string{}})
+
+	want := []Diagnostic{
+		{"test.n4l",4,4,SEVERITY_ERROR,"ERR_NO_SUCH_ARROW",SST.ERR_NO_SUCH_ARROW+"(nosuch)"},
+		{"test.n4l",5,11,SEVERITY_ERROR,"ERR_ARROW_SELFLOOP",ERR_ARROW_SELFLOOP},
+	}
+
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics %v, want %d",len(diags),diags,len(want))
+	}
+
+	for d := range want {
+		if diags[d] != want[d] {
+			t.Errorf("diagnostic %d = %v, want %v",d,diags[d],want[d])
+		}
+	}
+
+	// The line after the errors was still read
+
+	var found int
+
+	for _,n := range SST.GetMemoryNodes(g.PoSST) {
+		if n.S == "f" || n.S == "g" {
+			found++
+		}
+	}
+
+	if found != 2 {
+		t.Errorf("f (then) g was not parsed after the errors")
+	}
+
+	if !HasErrors(diags) {
+		t.Errorf("HasErrors = false")
+	}
+}
+
+// **************************************************************************
+
+func TestParseWarnings(t *testing.T) {
+
+	src := "-notes\n\n ONE BIG NOTE\n"
+
+	_,diags := Parse(strings.NewReader(src),"warn.n4l",Config{ConfigFiles: []string{}})
+
+	if len(diags) != 1 || diags[0].Code != "WARN_NOTE_TO_SELF" || diags[0].Line != 3 {
+		t.Fatalf("got %v, want one WARN_NOTE_TO_SELF at line 3",diags)
+	}
+
+	if HasErrors(diags) {
+		t.Errorf("a warning counted as an error")
+	}
+
+	if s := FormatDiagnostic(diags[0]); !strings.HasPrefix(s,"warn.n4l:3:2: warning WARN_NOTE_TO_SELF: ") {
+		t.Errorf("FormatDiagnostic = %s",s)
+	}
+}
+
+// **************************************************************************
+
+func TestMissingConfig(t *testing.T) {
+
+	_,diags := NewGraph(SST.OpenMemory(),Config{ConfigFiles: []string{"no/such/arrows.sst"}})
+
+	if len(diags) != 1 || diags[0].Code != "ERR_NO_SUCH_FILE_FOUND" {
+		t.Fatalf("got %v, want ERR_NO_SUCH_FILE_FOUND",diags)
+	}
+}
+
+// **************************************************************************
+
+func TestEveryMessageHasACode(t *testing.T) {
+
+	seen := make(map[string]bool)
+
+	for message,code := range DIAGNOSTIC_CODES {
+
+		if seen[code.Code] {
+			t.Errorf("code %s is used twice",code.Code)
+		}
+
+		seen[code.Code] = true
+
+		if message == "" || (code.Severity != SEVERITY_ERROR && code.Severity != SEVERITY_WARNING) {
+			t.Errorf("bad entry %s",code.Code)
+		}
+	}
+}
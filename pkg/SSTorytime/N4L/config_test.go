@@ -0,0 +1,93 @@
+package N4L
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+        SST "SSTorytime"
+)
+
+// **************************************************************************
+
+func TestConfigFilesIn(t *testing.T) {
+
+	dir := IncludeTestFiles(t,map[string]string{
+		"arrows-LT-1.sst": "",
+		"zoology.sst": "",
+		"annotations.sst": "",
+		"chemistry.sst": "",
+		"README": "",
+	})
+
+	var got []string
+
+	for _,file := range ConfigFilesIn(dir) {
+		got = append(got,filepath.Base(file))
+	}
+
+	want := append(append([]string{},CONFIG_FILES...),"chemistry.sst","zoology.sst")
+
+	if strings.Join(got,",") != strings.Join(want,",") {
+		t.Errorf("got %v, want %v",got,want)
+	}
+}
+
+// **************************************************************************
+
+func TestArrowCollisionAcrossFiles(t *testing.T) {
+
+	dir := IncludeTestFiles(t,map[string]string{
+		"shared.sst": "- leadsto\n\n + fetches (fetch) - is fetched by (fetched)\n",
+		"local.sst": "- leadsto\n + fetches (get) - is got by (got)\n",
+	})
+
+	files := []string{filepath.Join(dir,"shared.sst"),filepath.Join(dir,"local.sst")}
+
+	_,diags := NewGraph(SST.OpenMemory(),Config{ConfigFiles: files})
+
+	if len(diags) == 0 {
+		t.Fatal("no collision reported")
+	}
+
+	d := diags[0]
+
+	if d.Code != "ERR_ARR_REDEFINITION" || filepath.Base(d.File) != "local.sst" || d.Line != 2 {
+		t.Errorf("got %s",FormatDiagnostic(d))
+	}
+
+	if !strings.Contains(d.Message,"shared.sst:3") {
+		t.Errorf("doesn't say where the arrow was first defined: %s",d.Message)
+	}
+}
+
+// **************************************************************************
+
+func TestInlineArrows(t *testing.T) {
+
+	src := `-notes
+
+%arrows   # just for these notes
+- leadsto
+
+ + fetches (fetch) - is fetched by (fetched)
+%end
+
+ dog (fetch) ball
+`
+	g,diags := Parse(strings.NewReader(src),"inline.n4l",Config{ConfigFiles: []string{}})
+
+	if len(diags) > 0 {
+		t.Fatalf("unexpected %s",FormatDiagnostic(diags[0]))
+	}
+
+	if len(g.PageMap) != 1 || g.PageMap[0].Line != 9 {
+		t.Errorf("page map %v, want dog (fetch) ball at line 9",g.PageMap)
+	}
+
+	_,diags = Parse(strings.NewReader("-notes\n%arrows\n- leadsto\n\n a (then) b\n"),"open.n4l",Config{ConfigFiles: []string{}})
+
+	if len(diags) == 0 || diags[0].Code != "ERR_UNTERMINATED_ARROWS" || diags[0].Line != 2 {
+		t.Errorf("got %v, want ERR_UNTERMINATED_ARROWS at line 2",diags)
+	}
+}
@@ -0,0 +1,169 @@
+package N4L
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+        SST "SSTorytime"
+)
+
+// **************************************************************************
+
+func TestExportN4LRoundTrip(t *testing.T) {
+
+	// Notes exported from a graph must parse back to the same nodes,
+	// links and contexts
+
+	t.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	// What N4L can't say, see docs/exportN4L.md: annotations link to items
+	// outside the context, or leave text starting with a quote, and sequence
+	// mode links items across context blocks
+
+	limits := map[string]string{
+		"CFEngine.n4l": "annotations",
+		"SSTorytime.n4l": "annotations",
+		"kubernetes.n4l": "annotations",
+		"music-catalogue.n4l": "annotations",
+		"reasoning.n4l": "annotations",
+		"reminders.n4l": "annotations",
+		"tutorial.n4l": "annotations",
+		"unicode.n4l": "annotations",
+		"chinese_popsong.n4l": "sequence mode",
+		"moon.n4l": "sequence mode",
+	}
+
+	files,_ := filepath.Glob(SUITE_DIR+"/pass_*.in")
+	examples,_ := filepath.Glob("../../../examples/*.n4l")
+
+	for _,file := range examples {
+		if info,err := os.Stat(file); err == nil && info.Size() < LARGE_FILE/5 {
+			files = append(files,file)
+		}
+	}
+
+	for _,file := range files {
+
+		t.Run(filepath.Base(file),func(t *testing.T) {
+
+			if why,ok := limits[filepath.Base(file)]; ok {
+				t.Skip("uses",why)
+			}
+
+			before,diags := ExportTestGraph(t)
+			diags = append(diags,ParseFile(before,file,Config{})...)
+
+			if HasErrors(diags) {
+				t.Skip("doesn't parse")
+			}
+
+			text,err := SST.ExportN4LErr(before.PoSST,"")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			after,diags := ExportTestGraph(t)
+			diags = append(diags,ParseInto(after,strings.NewReader(text),"exported",Config{})...)
+
+			if HasErrors(diags) {
+				t.Fatalf("the export doesn't parse: %s\n%s",FormatDiagnostic(diags[0]),text)
+			}
+
+			want := ExportTestSummary(before.PoSST)
+			got := ExportTestSummary(after.PoSST)
+
+			if !slices.Equal(got,want) {
+				t.Errorf("the graph changed\n%s",GoldenDiff(strings.Join(want,"\n"),strings.Join(got,"\n")))
+			}
+		})
+	}
+}
+
+// **************************************************************************
+
+func TestExportN4LQuotedText(t *testing.T) {
+
+	// Items whose text has quotes or N4L punctuation in it, as they
+	// can be written
+
+	src := `-quotes
+
+ 'say "hello" twice' (then) "it's (mine)"
+ a "quoted" word (then) 'a "(quoted)" bracket'
+ 'the #1 "pick"' (then) "don't // stop"
+ " (then) 'spaced out '
+ "two
+ lines" (then) 'more "one" and "two"'
+`
+	before,diags := ExportTestGraph(t)
+	diags = append(diags,ParseInto(before,strings.NewReader(src),"quotes",Config{})...)
+
+	if len(diags) > 0 {
+		t.Fatalf("unexpected %s",FormatDiagnostic(diags[0]))
+	}
+
+	text := SST.ExportN4L(before.PoSST,"quotes")
+
+	after,diags := ExportTestGraph(t)
+	diags = append(diags,ParseInto(after,strings.NewReader(text),"exported",Config{})...)
+
+	if len(diags) > 0 {
+		t.Fatalf("the export doesn't parse: %s\n%s",FormatDiagnostic(diags[0]),text)
+	}
+
+	if want,got := ExportTestSummary(before.PoSST),ExportTestSummary(after.PoSST); !slices.Equal(got,want) {
+		t.Errorf("exported as\n%s\nthe graph changed\n%s",text,GoldenDiff(strings.Join(want,"\n"),strings.Join(got,"\n")))
+	}
+}
+
+// **************************************************************************
+
+func ExportTestGraph(t *testing.T) (*Graph,[]Diagnostic) {
+
+	t.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	return NewGraph(SST.OpenMemory(),Config{})
+}
+
+// **************************************************************************
+
+func ExportTestSummary(sst SST.PoSST) []string {
+
+	// The graph by text, since the node pointers depend on the order of
+	// the notes: each node and its chapters, and each link with its
+	// weight and context
+
+	var summary []string
+
+	nodes := SST.GetMemoryNodes(sst)
+	text := make(map[SST.NodePtr]string)
+
+	for _,n := range nodes {
+		text[n.NPtr] = n.S
+	}
+
+	for _,n := range nodes {
+
+		chapters := SST.ChapterList(n.Chap)
+		slices.Sort(chapters)
+
+		summary = append(summary,fmt.Sprintf("node %q in %s",n.S,strings.Join(chapters,", ")))
+
+		for stindex := range n.I {
+			for _,lnk := range n.I[stindex] {
+
+				arrow := SST.GetDBArrowByPtr(sst,lnk.Arr).Long
+				summary = append(summary,fmt.Sprintf("link %q (%s,%g) %q in %s",n.S,arrow,lnk.Wgt,text[lnk.Dst],SST.GetContext(sst,lnk.Ctx)))
+			}
+		}
+	}
+
+	slices.Sort(summary)
+
+	return slices.Compact(summary)
+}
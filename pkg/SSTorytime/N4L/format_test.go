@@ -0,0 +1,212 @@
+package N4L
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+        SST "SSTorytime"
+)
+
+// **************************************************************************
+
+func TestFormatLayout(t *testing.T) {
+
+	src := `# notes
+-  fruit
+:: fruit,food::
+@fr   apple   (pe)   "píngguǒ"  # what it's called
+    "     (then)  banana
+
+
+
+ +::sweet::
+cherry (pe,0.5) x // comment
+`
+
+	want := `# notes
+-fruit
+
+:: fruit, food ::
+
+ @fr apple (pe) "píngguǒ" # what it's called
+       " (then) banana
+
++:: sweet ::
+
+ cherry (pe,0.5) x // comment
+`
+	g := FormatTestGraph(t)
+
+	if got := Format(g,[]rune(src),FORMAT_ARROWS_AS_IS); got != want {
+		t.Errorf("got\n%s\nwant\n%s",got,want)
+	}
+
+	if got := Format(g,[]rune(want),FORMAT_ARROWS_AS_IS); got != want {
+		t.Errorf("not idempotent, got\n%s",got)
+	}
+}
+
+// **************************************************************************
+
+func TestFormatArrows(t *testing.T) {
+
+	g := FormatTestGraph(t)
+
+	tests := []struct{ in,arrows,want string }{
+		{"( pe )",FORMAT_ARROWS_AS_IS,"(pe)"},
+		{"(pe)",FORMAT_ARROWS_LONG,"(pinyin has english)"},
+		{"(pinyin has english,0.5)",FORMAT_ARROWS_SHORT,"(pe,0.5)"},
+		{"(pe, extra)",FORMAT_ARROWS_LONG,"(pinyin has english, extra)"},
+		{"(unknown)",FORMAT_ARROWS_LONG,"(unknown)"},
+	}
+
+	for _,tt := range tests {
+		if got := FormatArrow(g.PoSST,tt.in,tt.arrows); got != tt.want {
+			t.Errorf("FormatArrow(%q,%q) = %s, want %s",tt.in,tt.arrows,got,tt.want)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestFormatKeepsGraph(t *testing.T) {
+
+	// Every note file that parses cleanly must give the same graph after
+	// formatting, in each arrow style, and formatting twice changes nothing
+
+	t.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	files,_ := filepath.Glob(SUITE_DIR+"/pass_*.in")
+	examples,_ := filepath.Glob("../../../examples/*.n4l")
+
+	for _,file := range examples {
+		if info,err := os.Stat(file); err == nil && info.Size() < LARGE_FILE/5 {
+			files = append(files,file)
+		}
+	}
+
+	for _,file := range files {
+
+		t.Run(filepath.Base(file),func(t *testing.T) {
+
+			src,err := ReadFile(file)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _,arrows := range []string{FORMAT_ARROWS_AS_IS,FORMAT_ARROWS_SHORT,FORMAT_ARROWS_LONG} {
+
+				once,diags,err := FormatChecked(src,file,arrows,Config{})
+
+				if HasErrors(diags) {
+					t.Skip("doesn't parse")
+				}
+
+				if err != nil {
+					t.Fatalf("arrows %q: %v",arrows,err)
+				}
+
+				twice,_,err := FormatChecked([]rune(once),file,arrows,Config{})
+
+				if err != nil || twice != once {
+					t.Fatalf("arrows %q: not idempotent (%v)\n%s",arrows,err,GoldenDiff(once,twice))
+				}
+			}
+		})
+	}
+}
+
+// **************************************************************************
+
+func TestFormatSignature(t *testing.T) {
+
+	// A change to an item, an arrow or a context must show, or a
+	// formatting mistake would go unnoticed
+
+	src := "-fruit\n\n:: food ::\n\n apple (then) pear\n"
+
+	tests := []string{
+		"-fruit\n\n:: food ::\n\n apple (then) plum\n",
+		"-fruit\n\n:: food ::\n\n apple (pe) pear\n",
+		"-fruit\n\n:: drink ::\n\n apple (then) pear\n",
+		"-veg\n\n:: food ::\n\n apple (then) pear\n",
+	}
+
+	want := FormatSignature(FormatTestParse(t,src))
+
+	if got := FormatSignature(FormatTestParse(t,src)); got != want {
+		t.Errorf("same notes, different signature\n%s",GoldenDiff(want,got))
+	}
+
+	for _,changed := range tests {
+		if FormatSignature(FormatTestParse(t,changed)) == want {
+			t.Errorf("no change seen in %q",changed)
+		}
+	}
+}
+
+// **************************************************************************
+
+func BenchmarkFormatSignature(b *testing.B) {
+
+	// FormatChecked takes the signature of every file it formats, so it
+	// should grow linearly with the size of the notes
+
+	b.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	var notes strings.Builder
+
+	notes.WriteString("-bench\n\n")
+
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&notes," item %d (then) item %d\n",i,i+1)
+	}
+
+	g,diags := NewGraph(SST.OpenMemory(),Config{})
+	diags = append(diags,ParseInto(g,strings.NewReader(notes.String()),"bench",Config{})...)
+
+	if len(diags) > 0 {
+		b.Fatalf("unexpected %s",FormatDiagnostic(diags[0]))
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		FormatSignature(g)
+	}
+}
+
+// **************************************************************************
+// Helpers
+// **************************************************************************
+
+func FormatTestGraph(t *testing.T) *Graph {
+
+	t.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	g,diags := NewGraph(SST.OpenMemory(),Config{})
+
+	if len(diags) > 0 {
+		t.Fatalf("configuration: %s",FormatDiagnostic(diags[0]))
+	}
+
+	return g
+}
+
+// **************************************************************************
+
+func FormatTestParse(t *testing.T,src string) *Graph {
+
+	g := FormatTestGraph(t)
+
+	if diags := ParseInto(g,strings.NewReader(src),"test",Config{}); len(diags) > 0 {
+		t.Fatalf("unexpected %s",FormatDiagnostic(diags[0]))
+	}
+
+	return g
+}
+
@@ -0,0 +1,124 @@
+package N4L
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+        SST "SSTorytime"
+)
+
+// **************************************************************************
+
+func TestInclude(t *testing.T) {
+
+	dir := IncludeTestFiles(t,map[string]string{
+		"main.n4l": "-main\n\n%include words/glossary.n4l  # first\n\n apple (then) $glossary.2\n",
+		"words/glossary.n4l": "-glossary\n%export glossary\n\n@glossary fruit (then) banana\n",
+	})
+
+	g,diags := IncludeTestGraph(t)
+	diags = append(diags,ParseFile(g,filepath.Join(dir,"main.n4l"),Config{})...)
+
+	if len(diags) > 0 {
+		t.Fatalf("unexpected %s",FormatDiagnostic(diags[0]))
+	}
+
+	var linked bool
+
+	for _,n := range SST.GetMemoryNodes(g.PoSST) {
+		if n.S == "apple" {
+			for _,l := range n.I[SST.STTypeToSTIndex(SST.LEADSTO)] {
+				linked = linked || SST.GetMemoryNodeFromPtr(g.PoSST,l.Dst).S == "banana"
+			}
+		}
+	}
+
+	if !linked {
+		t.Errorf("$glossary.2 from the included file didn't resolve to banana")
+	}
+
+	// Each chapter is traced to the file that declared it
+
+	var chapters []string
+
+	for _,m := range g.Sources.Chapters {
+		chapters = append(chapters,m.Text+"@"+filepath.Base(m.File))
+	}
+
+	if len(chapters) != 2 || chapters[0] != "main@main.n4l" || chapters[1] != "glossary@glossary.n4l" {
+		t.Errorf("chapters %v, want main@main.n4l glossary@glossary.n4l",chapters)
+	}
+
+	// Named again on the command line, it isn't read twice
+
+	pages := len(g.PageMap)
+	ParseFile(g,filepath.Join(dir,"words/glossary.n4l"),Config{})
+
+	if len(g.PageMap) != pages {
+		t.Errorf("an included file was read again")
+	}
+}
+
+// **************************************************************************
+
+func TestIncludeErrors(t *testing.T) {
+
+	dir := IncludeTestFiles(t,map[string]string{
+		"a.n4l": "-a\n%include b.n4l\n%include nosuch.n4l\n%include\n",
+		"b.n4l": "-b\n%export nothing\n %include a.n4l\n",
+	})
+
+	g,diags := IncludeTestGraph(t)
+	diags = append(diags,ParseFile(g,filepath.Join(dir,"a.n4l"),Config{})...)
+
+	want := []struct{ file string; line int; code string }{
+		{"b.n4l",3,"ERR_INCLUDE_CYCLE"},
+		{"b.n4l",2,"ERR_NO_SUCH_EXPORT"},
+		{"a.n4l",3,"ERR_NO_SUCH_FILE_FOUND"},
+		{"a.n4l",4,"ERR_BAD_DIRECTIVE"},
+	}
+
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics %v, want %d",len(diags),diags,len(want))
+	}
+
+	for d := range want {
+		if filepath.Base(diags[d].File) != want[d].file || diags[d].Line != want[d].line || diags[d].Code != want[d].code {
+			t.Errorf("got %s, want %s:%d %s",FormatDiagnostic(diags[d]),want[d].file,want[d].line,want[d].code)
+		}
+	}
+}
+
+// **************************************************************************
+// Helpers
+// **************************************************************************
+
+func IncludeTestFiles(t *testing.T,files map[string]string) string {
+
+	dir := t.TempDir()
+
+	for name,text := range files {
+
+		path := filepath.Join(dir,name)
+
+		if err := os.MkdirAll(filepath.Dir(path),0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path,[]byte(text),0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// **************************************************************************
+
+func IncludeTestGraph(t *testing.T) (*Graph,[]Diagnostic) {
+
+	t.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	return NewGraph(SST.OpenMemory(),Config{})
+}
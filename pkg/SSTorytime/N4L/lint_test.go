@@ -0,0 +1,99 @@
+package N4L
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestLint(t *testing.T) {
+
+	t.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	src := `-notes
+
+@a Apple (then) pear
+@b apple (result) x
+ apple (no-result) x
+ lonely one
+ :: a, b, c, d, e, f, g ::
+ y (eq) z
+ z (!eq) y
+ q (then) $b.1
+`
+	g,diags := Parse(strings.NewReader(src),"lint.n4l",Config{})
+
+	if HasErrors(diags) {
+		t.Fatalf("doesn't parse: %s",FormatDiagnostic(diags[0]))
+	}
+
+	rules,_ := LintSelect("")
+
+	want := []string{
+		"lint.n4l:3:1 WARN_UNUSED_ALIAS",
+		"lint.n4l:4:4 WARN_DIFFERENT_CAPITALS",
+		"lint.n4l:5:20 WARN_CONTRADICTORY_ARROWS",
+		"lint.n4l:6:2 WARN_ISOLATED_ITEM",
+		"lint.n4l:7:2 WARN_LONG_CONTEXT",
+		"lint.n4l:9:10 WARN_CONTRADICTORY_ARROWS",
+	}
+
+	var got []string
+
+	for _,d := range Lint(g,diags,rules) {
+		got = append(got,fmt.Sprintf("%s:%d:%d %s",d.File,d.Line,d.Column,d.Code))
+	}
+
+	if strings.Join(got,"\n") != strings.Join(want,"\n") {
+		t.Errorf("got\n%s\nwant\n%s",strings.Join(got,"\n"),strings.Join(want,"\n"))
+	}
+
+	// The parser's own capitals warning is replaced, not repeated
+
+	rules["caps"] = false
+
+	for _,d := range Lint(g,diags,rules) {
+		if d.Code == "WARN_DIFFERENT_CAPITALS" {
+			t.Errorf("caps switched off, but got %s",FormatDiagnostic(d))
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestLintSelect(t *testing.T) {
+
+	tests := []struct{ spec string; on,off []string }{
+		{"",[]string{"caps","isolated"},[]string{"unused-arrow"}},
+		{"isolated",[]string{"isolated"},[]string{"caps","unused-arrow"}},
+		{"-isolated,+unused-arrow",[]string{"caps","unused-arrow"},[]string{"isolated"}},
+		{"all,-caps",[]string{"unused-arrow","long-context"},[]string{"caps"}},
+	}
+
+	for _,tt := range tests {
+
+		rules,err := LintSelect(tt.spec)
+
+		if err != nil {
+			t.Fatalf("%q: %v",tt.spec,err)
+		}
+
+		for _,name := range tt.on {
+			if !rules[name] {
+				t.Errorf("%q: %s is off",tt.spec,name)
+			}
+		}
+
+		for _,name := range tt.off {
+			if rules[name] {
+				t.Errorf("%q: %s is on",tt.spec,name)
+			}
+		}
+	}
+
+	if _,err := LintSelect("caps,nosuch"); err == nil {
+		t.Errorf("unknown rule accepted")
+	}
+}
@@ -0,0 +1,421 @@
+//**************************************************************
+//
+// Editor support for N4L notes, used by the n4l-lsp language
+// server: the parts of the Language Server Protocol it needs,
+// and finding arrows, aliases and sections under the cursor
+//
+//**************************************************************
+
+package lsp
+
+import (
+	"io"
+	"fmt"
+	"bufio"
+	"regexp"
+	"strings"
+	"strconv"
+	"unicode/utf16"
+
+        SST "SSTorytime"
+)
+
+//**************************************************************
+
+const (
+	// LSP protocol numbers
+
+	SYMBOL_NAMESPACE = 3
+	SYMBOL_KEY = 20
+)
+
+//**************************************************************
+
+type Position struct {
+
+	// Columns count UTF-16 code units, as in the protocol
+
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type DocumentSymbol struct {
+
+	Name           string      `json:"name"`
+	Detail         string      `json:"detail,omitempty"`
+	Kind           int         `json:"kind"`
+	Range          Range    `json:"range"`
+	SelectionRange Range    `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+//**************************************************************
+
+func TokenRange(lines []string,line,col int) Range {
+
+	// Underline the word the parser stopped at
+
+	var r Range
+
+	if line >= len(lines) {
+		line = len(lines)-1
+	}
+
+	runes := []rune(lines[line])
+
+	if col > len(runes) {
+		col = len(runes)
+	}
+
+	end := col
+
+	for end < len(runes) && runes[end] != ' ' && runes[end] != '\t' && runes[end] != '\r' {
+		end++
+	}
+
+	r.Start = Position{line,RuneToUTF16(runes,col)}
+	r.End = Position{line,RuneToUTF16(runes,end)}
+
+	return r
+}
+
+//**************************************************************
+
+func ArrowAt(line []rune,col int,closed bool) (int,int,bool) {
+
+	// Is col between ( and ), if closed, or just after ( if not?
+	// Return the span of the text inside
+
+	from := col
+
+	for from > 0 && line[from-1] != '(' {
+		if line[from-1] == ')' || line[from-1] == '"' {
+			return 0,0,false
+		}
+		from--
+	}
+
+	if from == 0 {
+		return 0,0,false
+	}
+
+	if !closed {
+		return from,col,true
+	}
+
+	to := col
+
+	for to < len(line) && line[to] != ')' {
+		if line[to] == '(' || line[to] == '"' {
+			return 0,0,false
+		}
+		to++
+	}
+
+	if to == len(line) {
+		return 0,0,false
+	}
+
+	return from,to,true
+}
+
+//**************************************************************
+
+func ArrowHover(sst SST.PoSST,name string) (string,bool) {
+
+	// The meaning of an arrow, and its inverse if it has one
+
+	ptr,ok := sst.ArrowShortDir[name]
+
+	if !ok {
+		ptr,ok = sst.ArrowLongDir[name]
+	}
+
+	if !ok {
+		return "",false
+	}
+
+	arr := sst.ArrowDirectory[ptr]
+
+	text := fmt.Sprintf("**(%s)** %s\n\nSTtype: %s",arr.Short,arr.Long,SST.STTypeName(SST.STIndexToSTType(arr.STAindex)))
+
+	if invptr,ok := sst.InverseArrows[ptr]; ok {
+		inv := sst.ArrowDirectory[invptr]
+		text += fmt.Sprintf("\n\ninverse: **(%s)** %s",inv.Short,inv.Long)
+	}
+
+	return text,true
+}
+
+//**************************************************************
+
+func AliasDefinition(text string,pos Position) (Range,bool) {
+
+	// From $alias.n or @alias to the line labelled @alias
+
+	var found Range
+	var ok bool
+
+	line,col := LineAt(text,pos)
+
+	from,to := WordAt(line,col)
+	word := string(line[from:to])
+
+	var name string
+
+	if strings.HasPrefix(word,"@") {
+		name = word[1:]
+	} else {
+		m := regexp.MustCompile(`^\$([^.\s]+)\.[0-9]+`).FindStringSubmatch(word)
+
+		if m == nil {
+			return found,false
+		}
+
+		name = m[1]
+	}
+
+	lines := strings.Split(text,"\n")
+
+	for l := range lines {
+
+		runes := []rune(lines[l])
+
+		for c := range runes {
+
+			if runes[c] != '@' || (c > 0 && runes[c-1] != ' ' && runes[c-1] != '\t') {
+				continue
+			}
+
+			f,t := WordAt(runes,c)
+
+			if string(runes[f+1:t]) != name {
+				continue
+			}
+
+			// The nearest label above the reference wins
+
+			if !ok || l <= pos.Line {
+				found.Start = Position{l,RuneToUTF16(runes,f)}
+				found.End = Position{l,RuneToUTF16(runes,t)}
+				ok = true
+			}
+		}
+	}
+
+	return found,ok
+}
+
+//**************************************************************
+
+func WordAt(line []rune,col int) (int,int) {
+
+	from,to := col,col
+
+	for from > 0 && !IsSpace(line[from-1]) {
+		from--
+	}
+
+	for to < len(line) && !IsSpace(line[to]) {
+		to++
+	}
+
+	return from,to
+}
+
+//**************************************************************
+
+func Outline(text string) []DocumentSymbol {
+
+	// -chapters, with the :: context :: blocks inside them
+
+	lines := strings.Split(text,"\n")
+
+	var chapters []DocumentSymbol = []DocumentSymbol{}
+	var loose []DocumentSymbol
+
+	for l := range lines {
+
+		text := strings.TrimSpace(lines[l])
+		runes := []rune(lines[l])
+
+		var sym DocumentSymbol
+
+		switch {
+
+		case strings.HasPrefix(text,"::") || strings.HasPrefix(text,"+::") || strings.HasPrefix(text,"-::"):
+			sym.Name = StripComment(text)
+			sym.Kind = SYMBOL_KEY
+
+		case strings.HasPrefix(text,"-") && len(text) > 1:
+			sym.Name = strings.TrimSpace(StripComment(text[1:]))
+			sym.Kind = SYMBOL_NAMESPACE
+
+		default:
+			continue
+		}
+
+		sym.Range = Range{Position{l,0},Position{l,RuneToUTF16(runes,len(runes))}}
+		sym.SelectionRange = sym.Range
+
+		if sym.Kind == SYMBOL_NAMESPACE {
+			CloseSymbols(chapters,l)
+			chapters = append(chapters,sym)
+			continue
+		}
+
+		if len(chapters) == 0 {
+			loose = append(loose,sym)
+			continue
+		}
+
+		last := &chapters[len(chapters)-1]
+		CloseSymbols(last.Children,l)
+		last.Children = append(last.Children,sym)
+	}
+
+	end := len(lines)-1
+
+	CloseSymbols(chapters,end+1)
+
+	if len(chapters) > 0 {
+		CloseSymbols(chapters[len(chapters)-1].Children,end+1)
+	}
+
+	CloseSymbols(loose,end+1)
+
+	return append(loose,chapters...)
+}
+
+//**************************************************************
+
+func CloseSymbols(list []DocumentSymbol,next int) {
+
+	// A section runs until the next one starts
+
+	if len(list) == 0 || next == 0 {
+		return
+	}
+
+	last := &list[len(list)-1]
+
+	if next-1 > last.Range.End.Line {
+		last.Range.End = Position{next-1,0}
+	}
+}
+
+//**************************************************************
+
+func StripComment(s string) string {
+
+	if i := strings.Index(s,"#"); i >= 0 {
+		s = s[:i]
+	}
+
+	if i := strings.Index(s,"//"); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSpace(s)
+}
+
+//**************************************************************
+
+func LineAt(text string,pos Position) ([]rune,int) {
+
+	// The line under the cursor, and the cursor as a rune index
+
+	lines := strings.Split(text,"\n")
+
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return nil,0
+	}
+
+	line := []rune(strings.TrimRight(lines[pos.Line],"\r"))
+
+	return line,UTF16ToRune(line,pos.Character)
+}
+
+//**************************************************************
+
+func RuneToUTF16(line []rune,col int) int {
+
+	// LSP counts columns in UTF-16 code units
+
+	if col > len(line) {
+		col = len(line)
+	}
+
+	return len(utf16.Encode(line[:col]))
+}
+
+//**************************************************************
+
+func UTF16ToRune(line []rune,units int) int {
+
+	count := 0
+
+	for r := range line {
+		if count >= units {
+			return r
+		}
+		count += len(utf16.Encode(line[r:r+1]))
+	}
+
+	return len(line)
+}
+
+//**************************************************************
+
+func IsSpace(r rune) bool {
+
+	return r == ' ' || r == '\t' || r == '\r'
+}
+
+//**************************************************************
+
+func ReadMessage(in *bufio.Reader) ([]byte,error) {
+
+	// JSON-RPC over stdio: headers, a blank line, then Content-Length
+	// bytes of JSON
+
+	length := -1
+
+	for {
+		header,err := in.ReadString('\n')
+
+		if err != nil {
+			return nil,err
+		}
+
+		header = strings.TrimSpace(header)
+
+		if header == "" {
+			break
+		}
+
+		if value,found := strings.CutPrefix(header,"Content-Length:"); found {
+			length,err = strconv.Atoi(strings.TrimSpace(value))
+
+			if err != nil {
+				return nil,fmt.Errorf("bad header %s",header)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil,fmt.Errorf("missing Content-Length")
+	}
+
+	msg := make([]byte,length)
+
+	_,err := io.ReadFull(in,msg)
+
+	return msg,err
+}
@@ -0,0 +1,182 @@
+package lsp
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+        SST "SSTorytime"
+)
+
+// **************************************************************************
+
+func TestUTF16Columns(t *testing.T) {
+
+	// é is one UTF-16 unit, 𝄞 is a surrogate pair
+
+	line := []rune("é𝄞 (then) x")
+
+	tests := []struct{ runes,units int }{{0,0},{1,1},{2,3},{3,4},{len(line),len(line)+1}}
+
+	for _,tt := range tests {
+
+		if got := RuneToUTF16(line,tt.runes); got != tt.units {
+			t.Errorf("rune %d is unit %d, want %d",tt.runes,got,tt.units)
+		}
+
+		if got := UTF16ToRune(line,tt.units); got != tt.runes {
+			t.Errorf("unit %d is rune %d, want %d",tt.units,got,tt.runes)
+		}
+	}
+
+	if r := TokenRange([]string{"a","é𝄞 (then) x"},1,3); r.Start != (Position{1,4}) || r.End != (Position{1,10}) {
+		t.Errorf("token range %v, want (then) in units 4-10",r)
+	}
+
+	if r := TokenRange([]string{"short"},3,99); r.Start != (Position{0,5}) || r.End != r.Start {
+		t.Errorf("past the end gave %v",r)
+	}
+}
+
+// **************************************************************************
+
+func TestArrowAt(t *testing.T) {
+
+	line := []rune(`a (then) "b (x)" c (`)
+
+	if from,to,ok := ArrowAt(line,5,true); !ok || string(line[from:to]) != "then" {
+		t.Errorf("got %q %v, want then",string(line[from:to]),ok)
+	}
+
+	if _,_,ok := ArrowAt(line,11,true); ok {
+		t.Errorf("found an arrow across a quote")
+	}
+
+	if _,_,ok := ArrowAt(line,len(line),true); ok {
+		t.Errorf("found a closed arrow after an open bracket")
+	}
+
+	if from,to,ok := ArrowAt(line,len(line),false); !ok || from != to {
+		t.Errorf("didn't complete after an open bracket")
+	}
+}
+
+// **************************************************************************
+
+func TestAliasDefinition(t *testing.T) {
+
+	text := "-notes\n\n@x first line\n a (then) $x.1\n\n@x second line\n b (then) $x.1\n"
+
+	tests := []struct{ line,col,want int }{
+		{3,11,2},  // $x.1 below the first label
+		{6,11,5},  // $x.1 below the second, nearest wins
+		{2,1,2},   // a label is its own definition
+		{5,1,5},
+	}
+
+	for _,tt := range tests {
+
+		r,ok := AliasDefinition(text,Position{tt.line,tt.col})
+
+		if !ok || r.Start.Line != tt.want || r.Start.Character != 0 || r.End.Character != 2 {
+			t.Errorf("line %d col %d went to %v %v, want line %d",tt.line,tt.col,r,ok,tt.want)
+		}
+	}
+
+	if _,ok := AliasDefinition(text,Position{3,2}); ok {
+		t.Errorf("a plain word has a definition")
+	}
+
+	if _,ok := AliasDefinition("a (then) $y.1",Position{0,10}); ok {
+		t.Errorf("an undefined alias has a definition")
+	}
+}
+
+// **************************************************************************
+
+func TestOutline(t *testing.T) {
+
+	text := ":: loose ::\n-first # comment\n:: a ::\n x\n+:: b ::\n-second\n y\n"
+
+	got := Outline(text)
+
+	if len(got) != 3 || got[0].Name != ":: loose ::" || got[1].Name != "first" || got[2].Name != "second" {
+		t.Fatalf("got %+v",got)
+	}
+
+	first := got[1]
+
+	if len(first.Children) != 2 || first.Children[0].Name != ":: a ::" || first.Children[1].Name != "+:: b ::" {
+		t.Fatalf("first has %+v",first.Children)
+	}
+
+	if first.Kind != SYMBOL_NAMESPACE || first.Children[0].Kind != SYMBOL_KEY {
+		t.Errorf("kinds %d %d",first.Kind,first.Children[0].Kind)
+	}
+
+	// Each runs until the next one at its level
+
+	if first.Range.Start.Line != 1 || first.Range.End.Line != 4 {
+		t.Errorf("first runs %v",first.Range)
+	}
+
+	if first.Children[0].Range.End.Line != 3 || first.Children[1].Range.End.Line != 4 {
+		t.Errorf("contexts run %v and %v",first.Children[0].Range,first.Children[1].Range)
+	}
+
+	if got[2].Range.End.Line != 7 {
+		t.Errorf("second runs %v, want to the end",got[2].Range)
+	}
+}
+
+// **************************************************************************
+
+func TestArrowHover(t *testing.T) {
+
+	sst := SST.OpenMemory()
+
+	if _,_,err := SST.DefineArrowErr(sst,SST.LEADSTO,"then","then","from","from"); err != nil {
+		t.Fatal(err)
+	}
+
+	text,ok := ArrowHover(sst,"then")
+
+	if !ok || !strings.Contains(text,"**(then)** then") || !strings.Contains(text,"inverse: **(from)** from") {
+		t.Errorf("got %q",text)
+	}
+
+	// No inverse, so no line for one
+
+	lonely := SST.InsertArrowDirectory(sst,"leadsto","lone","lonely","+")
+
+	if text,ok = ArrowHover(sst,"lonely"); !ok || strings.Contains(text,"inverse") {
+		t.Errorf("arrow %d without inverse got %q",lonely,text)
+	}
+
+	if _,ok = ArrowHover(sst,"nonesuch"); ok {
+		t.Errorf("an unknown arrow has a hover")
+	}
+}
+
+// **************************************************************************
+
+func TestReadMessage(t *testing.T) {
+
+	in := bufio.NewReader(strings.NewReader("Content-Length: 2\r\nContent-Type: x\r\n\r\n{}Content-Length: 3\r\n\r\n[1]Content-Type: x\r\n\r\n"))
+
+	for _,want := range []string{"{}","[1]"} {
+		if msg,err := ReadMessage(in); err != nil || string(msg) != want {
+			t.Errorf("got %q %v, want %q",msg,err,want)
+		}
+	}
+
+	if _,err := ReadMessage(in); err == nil || !strings.Contains(err.Error(),"Content-Length") {
+		t.Errorf("a message without a length gave %v",err)
+	}
+
+	in = bufio.NewReader(strings.NewReader("Content-Length: two\r\n\r\n"))
+
+	if _,err := ReadMessage(in); err == nil {
+		t.Errorf("a bad length was accepted")
+	}
+}
@@ -0,0 +1,220 @@
+package N4L
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+        SST "SSTorytime"
+)
+
+// **************************************************************************
+// The pass/fail/warn suite in tests/, compared with golden dumps of the
+// graph and diagnostics in tests/golden. After an intended change to the
+// parser, regenerate them with
+//
+//   go test -run TestSuite -update
+//
+// and review the diff before committing
+// **************************************************************************
+
+var UPDATE = flag.Bool("update",false,"rewrite the golden files in tests/golden")
+
+const (
+	SUITE_DIR = "../../../tests"
+	SUITE_CONFIG = "../../../SSTconfig"
+)
+
+// **************************************************************************
+
+func TestSuite(t *testing.T) {
+
+	t.Setenv("SST_CONFIG_PATH",SUITE_CONFIG)
+
+	files,_ := filepath.Glob(SUITE_DIR+"/*.in")
+
+	if len(files) == 0 {
+		t.Fatal("no test cases in",SUITE_DIR)
+	}
+
+	for _,file := range files {
+
+		name := filepath.Base(file)
+
+		t.Run(name,func(t *testing.T) {
+
+			g,diags := SuiteParse(t,file)
+
+			errors,warnings := 0,0
+
+			for d := range diags {
+				if diags[d].Severity == SEVERITY_ERROR {
+					errors++
+				} else {
+					warnings++
+				}
+			}
+
+			switch {
+			case strings.HasPrefix(name,"pass_") && errors > 0:
+				t.Errorf("expected to pass, got %d error(s): %s",errors,FormatDiagnostic(diags[0]))
+			case strings.HasPrefix(name,"fail_") && errors == 0:
+				t.Errorf("expected an error, got none")
+			case strings.HasPrefix(name,"warn_") && (errors > 0 || warnings == 0):
+				t.Errorf("expected only warnings, got %d error(s) and %d warning(s)",errors,warnings)
+			}
+
+			golden := SUITE_DIR+"/golden/"+name+".golden"
+			got := GoldenDump(g,diags)
+
+			if *UPDATE {
+				if err := os.WriteFile(golden,[]byte(got),0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want,err := os.ReadFile(golden)
+
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)",err)
+			}
+
+			if got != string(want) {
+				t.Errorf("differs from %s\n%s",golden,GoldenDiff(string(want),got))
+			}
+		})
+	}
+}
+
+// **************************************************************************
+
+func SuiteParse(t *testing.T,file string) (*Graph,[]Diagnostic) {
+
+	r,err := os.Open(file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	g,diags := NewGraph(SST.OpenMemory(),Config{})
+
+	if len(diags) > 0 {
+		t.Fatalf("configuration: %s",FormatDiagnostic(diags[0]))
+	}
+
+	return g,ParseInto(g,r,filepath.Base(file),Config{})
+}
+
+// **************************************************************************
+
+func GoldenDump(g *Graph,diags []Diagnostic) string {
+
+	// Only what the notes produced, not the arrows from SSTconfig
+
+	sst := g.PoSST
+
+	var out string
+
+	out += "# diagnostics\n"
+
+	for d := range diags {
+		out += FormatDiagnostic(diags[d]) + "\n"
+	}
+
+	out += "# nodes\n"
+
+	for _,n := range SST.GetMemoryNodes(sst) {
+
+		out += fmt.Sprintf("%v %q chapter %q",n.NPtr,n.S,n.Chap)
+
+		if n.Seq {
+			out += " seq"
+		}
+
+		out += "\n"
+
+		for st := range n.I {
+			for _,l := range n.I[st] {
+				if l.Arr == 0 {
+					out += fmt.Sprintf("  context %d\n",l.Ctx)
+				} else {
+					out += "  " + GoldenLink(sst,l) + "\n"
+				}
+			}
+		}
+	}
+
+	out += "# contexts\n"
+
+	for c := range sst.ContextDirectory {
+		out += fmt.Sprintf("%d %q\n",sst.ContextDirectory[c].Ptr,sst.ContextDirectory[c].Context)
+	}
+
+	out += "# page map\n"
+
+	for _,p := range sst.PageMap {
+
+		out += fmt.Sprintf("line %d chapter %q alias %q context %d\n",p.Line,p.Chapter,p.Alias,p.Context)
+
+		for l := range p.Path {
+			if l == 0 {
+				out += fmt.Sprintf("  %v\n",p.Path[l].Dst)
+			} else {
+				out += "  " + GoldenLink(sst,p.Path[l]) + "\n"
+			}
+		}
+	}
+
+	return out
+}
+
+// **************************************************************************
+
+func GoldenLink(sst SST.PoSST,l SST.Link) string {
+
+	return fmt.Sprintf("(%s,%g) context %d -> %v",sst.ArrowDirectory[l.Arr].Short,l.Wgt,l.Ctx,l.Dst)
+}
+
+// **************************************************************************
+
+func GoldenDiff(want,got string) string {
+
+	// Just the first few lines that differ, enough to see what happened
+
+	w := strings.Split(want,"\n")
+	g := strings.Split(got,"\n")
+
+	var out string
+	var shown int
+
+	for i := 0; i < len(w) || i < len(g); i++ {
+
+		var wl,gl string
+
+		if i < len(w) {
+			wl = w[i]
+		}
+
+		if i < len(g) {
+			gl = g[i]
+		}
+
+		if wl != gl {
+			out += fmt.Sprintf("line %d\n  want: %s\n  got:  %s\n",i+1,wl,gl)
+			shown++
+		}
+
+		if shown == 5 {
+			out += "...\n"
+			break
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,132 @@
+//**************************************************************
+//
+// Watching notes for N4L -watch: which files changed since the
+// last look, and which of them write the chapters that changed
+//
+//**************************************************************
+
+package watch
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"path/filepath"
+
+        SST "SSTorytime"
+        N4L "SSTorytime/N4L"
+)
+
+//**************************************************************
+
+func Stamps(args []string) map[string]string {
+
+	// Every .n4l file under the arguments, with its modification time
+	// and size. Hidden directories, like .git, are skipped
+
+	var stamps = make(map[string]string)
+
+	for _,arg := range args {
+
+		filepath.WalkDir(arg,func(path string,d fs.DirEntry,err error) error {
+
+			if err != nil {
+				return nil
+			}
+
+			if d.IsDir() {
+				if path != arg && strings.HasPrefix(d.Name(),".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if path != arg && !strings.HasSuffix(path,".n4l") {
+				return nil
+			}
+
+			info,err := d.Info()
+
+			if err == nil {
+				stamps[path] = fmt.Sprint(info.ModTime().UnixNano(),":",info.Size())
+			}
+
+			return nil
+		})
+	}
+
+	return stamps
+}
+
+//**************************************************************
+
+func Changes(before,after map[string]string) []string {
+
+	var changes []string
+
+	for file,stamp := range after {
+		if before[file] != stamp {
+			changes = append(changes,file)
+		}
+	}
+
+	for file := range before {
+		if _,ok := after[file]; !ok {
+			changes = append(changes,file+" (removed)")
+		}
+	}
+
+	sort.Strings(changes)
+	return changes
+}
+
+//**************************************************************
+
+func Chapters(graph *N4L.Graph) map[string]string {
+
+	// Each chapter written out as N4L, to compare with the last sync
+
+	var chapters = make(map[string]string)
+
+	for _,chapter := range SST.GetMemoryChapters(graph.PoSST) {
+		chapters[chapter] = SST.FormatN4LChapter(graph.PoSST,SST.GetMemoryChapterExport(graph.PoSST,chapter))
+	}
+
+	return chapters
+}
+
+//**************************************************************
+
+func FilesFor(graph *N4L.Graph,files []string,chapters map[string]bool) []string {
+
+	// The files that write to these chapters, or all of them if one of
+	// those was only included from outside the watched directories
+
+	var want = make(map[string]bool)
+
+	for _,m := range graph.Sources.Chapters {
+		if chapters[strings.TrimSpace(m.Text)] {
+			abs,_ := filepath.Abs(m.File)
+			want[abs] = true
+		}
+	}
+
+	var subset []string
+
+	for _,file := range files {
+
+		abs,_ := filepath.Abs(file)
+
+		if want[abs] {
+			subset = append(subset,file)
+			delete(want,abs)
+		}
+	}
+
+	if len(want) > 0 {
+		return files
+	}
+
+	return subset
+}
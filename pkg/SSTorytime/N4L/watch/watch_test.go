@@ -0,0 +1,198 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+        SST "SSTorytime"
+        N4L "SSTorytime/N4L"
+)
+
+// **************************************************************************
+
+func TestStamps(t *testing.T) {
+
+	dir := WatchTestFiles(t,map[string]string{
+		"a.n4l": "-a\n",
+		"notes.txt": "not notes",
+		"sub/b.n4l": "-b\n",
+		".git/c.n4l": "-c\n",
+	})
+
+	other := filepath.Join(t.TempDir(),"named.txt")
+
+	if err := os.WriteFile(other,[]byte("-named\n"),0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stamps := Stamps([]string{dir,other})
+
+	var got []string
+
+	for file := range stamps {
+		got = append(got,filepath.Base(file))
+	}
+
+	slices.Sort(got)
+
+	// A file named on the command line counts whatever it is called
+
+	if strings.Join(got,",") != "a.n4l,b.n4l,named.txt" {
+		t.Errorf("got %v, want a.n4l, b.n4l and named.txt",got)
+	}
+
+	// A change of size shows, even within the clock resolution
+
+	a := filepath.Join(dir,"a.n4l")
+	before := stamps[a]
+
+	if err := os.WriteFile(a,[]byte("-a\n x (then) y\n"),0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if Stamps([]string{dir})[a] == before {
+		t.Errorf("stamp of a.n4l didn't change")
+	}
+}
+
+// **************************************************************************
+
+func TestChanges(t *testing.T) {
+
+	before := map[string]string{"same": "1","edited": "1","gone": "1"}
+	after := map[string]string{"same": "1","edited": "2","added": "1"}
+
+	got := Changes(before,after)
+
+	if strings.Join(got,",") != "added,edited,gone (removed)" {
+		t.Errorf("got %v",got)
+	}
+
+	if got := Changes(after,after); len(got) != 0 {
+		t.Errorf("no change gave %v",got)
+	}
+}
+
+// **************************************************************************
+
+func TestChaptersAndFiles(t *testing.T) {
+
+	dir := WatchTestFiles(t,map[string]string{
+		"fruit.n4l": "-fruit\n\n apple (then) pear\n",
+		"veg.n4l": "-veg\n\n leek (then) onion\n",
+		"both.n4l": "-fruit\n\n plum (then) cherry\n\n-nuts\n\n hazel (then) walnut\n",
+	})
+
+	files := []string{filepath.Join(dir,"both.n4l"),filepath.Join(dir,"fruit.n4l"),filepath.Join(dir,"veg.n4l")}
+
+	g,diags := WatchTestGraph(t)
+
+	for _,file := range files {
+		diags = append(diags,N4L.ParseFile(g,file,N4L.Config{})...)
+	}
+
+	if len(diags) > 0 {
+		t.Fatalf("unexpected %s",N4L.FormatDiagnostic(diags[0]))
+	}
+
+	chapters := Chapters(g)
+
+	if len(chapters) != 3 || !strings.Contains(chapters["fruit"],"apple") || !strings.Contains(chapters["fruit"],"plum") || strings.Contains(chapters["veg"],"apple") {
+		t.Errorf("got %v",chapters)
+	}
+
+	tests := []struct{ chapters []string; want []string }{
+		{[]string{"veg"},[]string{"veg.n4l"}},
+		{[]string{"fruit"},[]string{"both.n4l","fruit.n4l"}},
+		{[]string{"nuts","veg"},[]string{"both.n4l","veg.n4l"}},
+		{[]string{"nosuch"},nil},
+	}
+
+	for _,tt := range tests {
+
+		changed := make(map[string]bool)
+
+		for _,chapter := range tt.chapters {
+			changed[chapter] = true
+		}
+
+		var got []string
+
+		for _,file := range FilesFor(g,files,changed) {
+			got = append(got,filepath.Base(file))
+		}
+
+		if !slices.Equal(got,tt.want) {
+			t.Errorf("%v: got %v, want %v",tt.chapters,got,tt.want)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestFilesForOutsideInclude(t *testing.T) {
+
+	// A chapter written by a file that isn't watched, but included from
+	// one that is, needs everything parsed again
+
+	outside := WatchTestFiles(t,map[string]string{
+		"glossary.n4l": "-glossary\n%export glossary\n\n@glossary fruit (then) banana\n",
+	})
+
+	dir := WatchTestFiles(t,map[string]string{
+		"main.n4l": "-main\n\n%include "+filepath.Join(outside,"glossary.n4l")+"\n\n apple (then) $glossary.2\n",
+		"other.n4l": "-other\n\n leek (then) onion\n",
+	})
+
+	files := []string{filepath.Join(dir,"main.n4l"),filepath.Join(dir,"other.n4l")}
+
+	g,diags := WatchTestGraph(t)
+
+	for _,file := range files {
+		diags = append(diags,N4L.ParseFile(g,file,N4L.Config{})...)
+	}
+
+	if len(diags) > 0 {
+		t.Fatalf("unexpected %s",N4L.FormatDiagnostic(diags[0]))
+	}
+
+	if got := FilesFor(g,files,map[string]bool{"glossary": true}); !slices.Equal(got,files) {
+		t.Errorf("got %v, want every file",got)
+	}
+}
+
+// **************************************************************************
+// Helpers
+// **************************************************************************
+
+func WatchTestFiles(t *testing.T,files map[string]string) string {
+
+	dir := t.TempDir()
+
+	for name,text := range files {
+
+		path := filepath.Join(dir,name)
+
+		if err := os.MkdirAll(filepath.Dir(path),0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path,[]byte(text),0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// **************************************************************************
+
+func WatchTestGraph(t *testing.T) (*N4L.Graph,[]N4L.Diagnostic) {
+
+	t.Setenv("SST_CONFIG_PATH","../../../../SSTconfig")
+
+	return N4L.NewGraph(SST.OpenMemory(),N4L.Config{})
+}
@@ -0,0 +1,255 @@
+package SSTorytime
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestArchiveRoundTrip(t *testing.T) {
+
+	sst := StorageTestGraph(t)
+	door := GetDBNodePtrMatchingName(sst,"door","")[0]
+	UpdateLastSawNPtr(sst,door.Class,int(door.CPtr),"door")
+
+	var buf bytes.Buffer
+
+	if err := WriteArchive(&buf,MemoryArchive(sst)); err != nil {
+		t.Fatal(err)
+	}
+
+	ar,err := ReadArchive(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := OpenMemory()
+	LoadArchive(restored,ar)
+
+	if !reflect.DeepEqual(GetMemoryNodes(restored),GetMemoryNodes(sst)) {
+		t.Errorf("nodes differ after restoring")
+	}
+
+	if !reflect.DeepEqual(restored.ArrowDirectory,sst.ArrowDirectory) || !reflect.DeepEqual(restored.InverseArrows,sst.InverseArrows) {
+		t.Errorf("arrows differ after restoring")
+	}
+
+	if !reflect.DeepEqual(restored.ContextDirectory,sst.ContextDirectory) || !reflect.DeepEqual(restored.PageMap,sst.PageMap) {
+		t.Errorf("contexts or page map differ after restoring")
+	}
+
+	if ls := GetLastSawNPtr(restored,door); ls.Freq != 1 {
+		t.Errorf("last seen not restored, got %v",ls)
+	}
+}
+
+// **************************************************************************
+
+func TestArchiveChapterMerge(t *testing.T) {
+
+	src := StorageTestGraph(t)
+	ArchiveTestContext(src,"gate",[]string{"garden"})
+
+	ar := MemoryArchive(src)
+
+	// Another graph, numbered differently, that already knows the gate
+
+	sst := OpenMemory()
+
+	if _,_,err := DefineArrowErr(sst,LEADSTO,"fetches","fetch","is fetched by","fetched"); err != nil {
+		t.Fatal(err)
+	}
+
+	Vertex(sst,"apple","orchard")
+	Vertex(sst,"gate","orchard")
+
+	merge,err := LoadArchiveChapter(sst,ar,"garden")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// gate -> doors is kept, the inverses of start -> gate and door -> doors
+	// point outside the chapter
+
+	if merge.Nodes != 2 || merge.Outside != 2 || merge.Lines != 0 {
+		t.Errorf("got %+v, want 2 nodes and 2 outside links",merge)
+	}
+
+	gate := GetDBNodePtrMatchingName(sst,"gate","")
+
+	if len(gate) != 1 || GetDBNodeByNodePtr(sst,gate[0]).Chap != "orchard,garden" {
+		t.Fatalf("gate should be shared by both chapters, got %v",gate)
+	}
+
+	then,_ := GetDBArrowsWithArrowName(sst,"then")
+	lnks := GetDBNodeByNodePtr(sst,gate[0]).I[STTypeToSTIndex(LEADSTO)]
+
+	if len(lnks) != 2 || lnks[0].Arr != then || GetDBNodeByNodePtr(sst,lnks[0].Dst).S != "doors" {
+		t.Errorf("gate links %v, want then doors and its context",lnks)
+	}
+
+	if ctx := GetContext(sst,lnks[0].Ctx); ctx != "garden" {
+		t.Errorf("link context %q, want garden",ctx)
+	}
+
+	if ctx := ArchiveTestNodeContext(sst,"gate"); ctx != "garden" {
+		t.Errorf("gate has context %q, want garden",ctx)
+	}
+
+	if len(sst.PageMap) != 1 || sst.PageMap[0].Line != 5 {
+		t.Errorf("page map %v, want line 5 of garden",sst.PageMap)
+	}
+
+	if _,err := LoadArchiveChapter(sst,ar,"cellar"); !errors.Is(err,ErrNoSuchChapter) {
+		t.Errorf("got %v, want ErrNoSuchChapter",err)
+	}
+}
+
+// **************************************************************************
+
+func TestArchiveMergeConflicts(t *testing.T) {
+
+	src := StorageTestGraph(t)
+	ar := MemoryArchive(src)
+
+	// Here then is a containment, and Gate is capitalized
+
+	sst := OpenMemory()
+
+	if _,_,err := DefineArrowErr(sst,CONTAINS,"then","then","from","from"); err != nil {
+		t.Fatal(err)
+	}
+
+	Vertex(sst,"Gate","orchard")
+
+	if _,err := LoadArchiveChapter(sst,ar,"garden"); !errors.Is(err,ErrArrowConflict) {
+		t.Fatalf("got %v, want ErrArrowConflict",err)
+	}
+
+	if len(GetMemoryNodes(sst)) != 1 {
+		t.Errorf("a refused chapter left nodes behind")
+	}
+
+	merge,err := LoadArchiveChapters(sst,ar,nil,"team")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(merge.Chapters,[]string{"garden","house"}) || merge.Nodes != 4 {
+		t.Errorf("got chapters %v and %d nodes, want garden, house and 4",merge.Chapters,merge.Nodes)
+	}
+
+	// Each of the four edges has a link both ways
+
+	if len(merge.Conflicts) != 2 || merge.Conflicting != 8 || merge.Outside != 0 {
+		t.Errorf("got %v, %d conflicting and %d outside links",merge.Conflicts,merge.Conflicting,merge.Outside)
+	}
+
+	if len(merge.Warnings) != 1 || !strings.Contains(merge.Warnings[0],"gate") {
+		t.Errorf("warnings %v, want one about gate",merge.Warnings)
+	}
+
+	// Where the gate came from, it has no links left to search by
+
+	was := GetDBNodePtrMatchingName(src,"gate","")[0]
+	gate := NO_NODE_PTR
+
+	for _,n := range GetMemoryNodes(sst) {
+		if n.S == "gate" {
+			gate = n.NPtr
+		}
+	}
+
+	sources := GetNodeSources(sst,gate)
+
+	if len(sources) != 1 || sources[0].Source != "team" || sources[0].SrcNPtr != was {
+		t.Errorf("sources %v, want team %v",sources,was)
+	}
+
+	if _,err := LoadArchiveChapters(sst,ar,[]string{"house","cellar"},"team"); !errors.Is(err,ErrNoSuchChapter) {
+		t.Errorf("got %v, want ErrNoSuchChapter",err)
+	}
+}
+
+// **************************************************************************
+
+func TestArchiveMergeContexts(t *testing.T) {
+
+	src := StorageTestGraph(t)
+
+	ArchiveTestContext(src,"start",[]string{"outside"})
+	ArchiveTestContext(src,"gate",[]string{"garden"})
+
+	sst := OpenMemory()
+	merge,err := LoadArchiveChapters(sst,MemoryArchive(src),nil,"")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merge.Outside != 0 {
+		t.Errorf("got %d outside links, want none",merge.Outside)
+	}
+
+	for name,want := range map[string]string{"start": "outside","gate": "garden","door": ""} {
+		if got := ArchiveTestNodeContext(sst,name); got != want {
+			t.Errorf("%s has context %q, want %q",name,got,want)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestArchiveErrors(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	ar := MemoryArchive(OpenMemory())
+	ar.Version = ARCHIVE_VERSION+1
+
+	if err := WriteArchive(&buf,ar); err != nil {
+		t.Fatal(err)
+	}
+
+	for _,r := range []*strings.Reader{strings.NewReader("-notes\n"),strings.NewReader(buf.String())} {
+		if _,err := ReadArchive(r); !errors.Is(err,ErrArchive) {
+			t.Errorf("got %v, want ErrArchive",err)
+		}
+	}
+}
+
+// **************************************************************************
+// Helpers
+// **************************************************************************
+
+func ArchiveTestContext(sst PoSST,name string,context []string) {
+
+	// N4L records a node's context as a link by the empty arrow to nowhere
+
+	nptr := GetDBNodePtrMatchingName(sst,name,"")[0]
+	AppendLinkToNode(sst,nptr,Link{Arr: 0,Wgt: 1,Ctx: TryContext(sst,context)},NodePtr{})
+}
+
+// **************************************************************************
+
+func ArchiveTestNodeContext(sst PoSST,name string) string {
+
+	for _,n := range GetMemoryNodes(sst) {
+		if n.S == name {
+			for _,l := range n.I[STTypeToSTIndex(LEADSTO)] {
+				if l.Arr == 0 {
+					return GetContext(sst,l.Ctx)
+				}
+			}
+		}
+	}
+
+	return ""
+}
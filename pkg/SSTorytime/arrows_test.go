@@ -0,0 +1,167 @@
+package SSTorytime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestMergeArrowDirectories(t *testing.T) {
+
+	lt := STTypeToSTIndex(LEADSTO)
+	nlt := STTypeToSTIndex(-LEADSTO)
+
+	db := []ArrowDirectory{
+		{lt,"leads to","lt",0},
+		{nlt,"comes from","cf",1},
+	}
+
+	dbinv := map[ArrowPtr]ArrowPtr{0: 1, 1: 0}
+
+	// Parsed without the database, so numbered differently
+
+	mem := []ArrowDirectory{
+		{lt,"fetches","fetch",0},
+		{nlt,"is fetched by","fetched",1},
+		{lt,"Leads To","LT",2},
+		{nlt,"comes from","cf",3},
+	}
+
+	meminv := map[ArrowPtr]ArrowPtr{0: 1, 1: 0, 2: 3, 3: 2}
+
+	merge,err := MergeArrowDirectories(db,dbinv,mem,meminv)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[ArrowPtr]ArrowPtr{0: 2, 1: 3, 2: 0, 3: 1}; !reflect.DeepEqual(merge.Remap,want) {
+		t.Errorf("remap %v, want %v",merge.Remap,want)
+	}
+
+	if len(merge.New) != 2 || merge.New[0].Ptr != 2 || merge.New[1].Long != "is fetched by" {
+		t.Errorf("new arrows %v",merge.New)
+	}
+
+	if want := [][2]ArrowPtr{{2,3},{3,2}}; !reflect.DeepEqual(merge.NewInverses,want) {
+		t.Errorf("new inverses %v, want %v",merge.NewInverses,want)
+	}
+
+	// Links follow the new numbering
+
+	links := []Link{{Arr: 0},{Arr: 3},{Arr: 7}}
+	RemapLinkArrows(links,merge.Remap)
+
+	if links[0].Arr != 2 || links[1].Arr != 1 || links[2].Arr != 7 {
+		t.Errorf("remapped links %v",links)
+	}
+}
+
+// **************************************************************************
+
+func TestMergeArrowConflicts(t *testing.T) {
+
+	lt := STTypeToSTIndex(LEADSTO)
+	nlt := STTypeToSTIndex(-LEADSTO)
+
+	db := []ArrowDirectory{
+		{lt,"leads to","lt",0},
+		{nlt,"comes from","cf",1},
+	}
+
+	dbinv := map[ArrowPtr]ArrowPtr{0: 1, 1: 0}
+
+	tests := []struct{ name string; mem []ArrowDirectory; inv map[ArrowPtr]ArrowPtr }{
+		{"other short name",[]ArrowDirectory{{lt,"leads to","then",0}},nil},
+		{"short name taken",[]ArrowDirectory{{lt,"results in","lt",0}},nil},
+		{"different type",[]ArrowDirectory{{STTypeToSTIndex(CONTAINS),"leads to","lt",0}},nil},
+		{"different inverse",[]ArrowDirectory{{lt,"leads to","lt",0},{nlt,"follows","fol",1}},map[ArrowPtr]ArrowPtr{0: 1}},
+	}
+
+	for _,tt := range tests {
+		if _,err := MergeArrowDirectories(db,dbinv,tt.mem,tt.inv); !errors.Is(err,ErrArrowConflict) {
+			t.Errorf("%s: got %v, want ErrArrowConflict",tt.name,err)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestDefineArrowInMemory(t *testing.T) {
+
+	sst := OpenMemory()
+
+	fwd,bwd,err := DefineArrowErr(sst,LEADSTO,"fetches","fetch","is fetched by","fetched")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if GetInverseArrow(sst,fwd) != bwd || GetInverseArrow(sst,bwd) != fwd {
+		t.Errorf("inverse not registered: %d %d",fwd,bwd)
+	}
+
+	if ptr,sttype,err := GetDBArrowsWithArrowNameErr(sst,"fetched"); err != nil || ptr != bwd || sttype != -LEADSTO {
+		t.Errorf("got %d %d %v, want %d %d",ptr,sttype,err,bwd,-LEADSTO)
+	}
+
+	// Again is harmless, and similarity is its own inverse
+
+	if again,_,err := DefineArrowErr(sst,LEADSTO,"fetches","fetch","is fetched by","fetched"); err != nil || again != fwd {
+		t.Errorf("redefinition gave %d %v",again,err)
+	}
+
+	if near,inv,err := DefineArrowErr(sst,NEAR,"resembles","like","",""); err != nil || near != inv {
+		t.Errorf("similarity gave %d %d %v",near,inv,err)
+	}
+
+	tests := []struct{ name string; sttype int; long,short,invlong,invshort string; want error }{
+		{"out of bounds",4,"a","b","c","d",ErrSTOutOfBounds},
+		{"empty name",LEADSTO,"a","","c","d",ErrBadArrowName},
+		{"name taken",LEADSTO,"retrieves","fetch","is retrieved by","retrieved",ErrArrowConflict},
+		{"different type",CONTAINS,"fetches","fetch","is fetched by","fetched",ErrArrowConflict},
+	}
+
+	for _,tt := range tests {
+		if _,_,err := DefineArrowErr(sst,tt.sttype,tt.long,tt.short,tt.invlong,tt.invshort); !errors.Is(err,tt.want) {
+			t.Errorf("%s: got %v, want %v",tt.name,err,tt.want)
+		}
+	}
+
+	// A failed definition leaves nothing behind
+
+	if _,_,err := GetDBArrowsWithArrowNameErr(sst,"retrieved"); err == nil {
+		t.Errorf("half of a conflicting definition was kept")
+	}
+}
+
+// **************************************************************************
+
+func TestDefineArrowIsAllOrNothing(t *testing.T) {
+
+	sst := OpenMemory()
+
+	before := len(sst.ArrowDirectory)
+
+	// The forward arrow is fine on its own, but the inverse takes its short name
+
+	if _,_,err := DefineArrowErr(sst,LEADSTO,"pushes","push","is pushed by","push"); !errors.Is(err,ErrArrowConflict) {
+		t.Fatalf("got %v, want %v",err,ErrArrowConflict)
+	}
+
+	if _,_,err := GetDBArrowsWithArrowNameErr(sst,"pushes"); err == nil {
+		t.Errorf("the forward arrow was kept without its inverse")
+	}
+
+	if len(sst.ArrowDirectory) != before || len(sst.ArrowShortDir) != before || len(sst.InverseArrows) != 0 {
+		t.Errorf("directories changed: %d arrows, %d short names, %d inverses",len(sst.ArrowDirectory),len(sst.ArrowShortDir),len(sst.InverseArrows))
+	}
+
+	fwd,bwd,err := DefineArrowErr(sst,LEADSTO,"pushes","push","is pushed by","pushed")
+
+	if err != nil || GetInverseArrow(sst,fwd) != bwd || sst.ArrowDirectoryTop != ArrowPtr(before+2) {
+		t.Errorf("got %d %d %v after a failed definition",fwd,bwd,err)
+	}
+}
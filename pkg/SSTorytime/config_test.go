@@ -0,0 +1,116 @@
+package SSTorytime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestGetDBConfigPrecedence(t *testing.T) {
+
+	var tests = []struct {
+		name string
+		file string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "defaults",
+			want: "user=sstoryline dbname=sstoryline password=sst_1234 sslmode=disable",
+		},
+		{
+			name: "file fields",
+			file: "user: alice\npasswd: secret\ndbname: notes\nhost: db.local\nport: 5433\nsslmode: require\n",
+			want: "host=db.local port=5433 user=alice dbname=notes password=secret sslmode=require",
+		},
+		{
+			name: "env over file fields",
+			file: "user: alice\nhost: db.local\n",
+			env:  map[string]string{"SST_DB_HOST": "postgres","SST_DB_PASSWORD": "two words"},
+			want: "host=postgres user=alice dbname=sstoryline password='two words' sslmode=disable",
+		},
+		{
+			name: "file url over file fields",
+			file: "user: alice\nurl: postgres://bob:pw@remote:5432/graph?sslmode=verify-full\n",
+			want: "postgres://bob:pw@remote:5432/graph?sslmode=verify-full",
+		},
+		{
+			name: "env fields over file url",
+			file: "url: postgres://bob:pw@remote:5432/graph?sslmode=verify-full\n",
+			env:  map[string]string{"SST_DB_HOST": "postgres","SST_DB_SSLMODE": "disable"},
+			want: "host=postgres port=5432 user=bob dbname=graph password=pw sslmode=disable",
+		},
+		{
+			name: "env fields keep other url parameters",
+			file: "url: postgres://bob@remote/graph?sslmode=verify-full&sslrootcert=/etc/ca.pem&connect_timeout=5&application_name=my%20notes\n",
+			env:  map[string]string{"SST_DB_PASSWORD": "pw"},
+			want: "host=remote user=bob dbname=graph password=pw sslmode=verify-full application_name='my notes' connect_timeout=5 sslrootcert=/etc/ca.pem",
+		},
+		{
+			name: "env url over env fields",
+			file: "url: postgres://bob:pw@remote:5432/graph\n",
+			env:  map[string]string{"SST_DB_HOST": "postgres","SST_DB_URL": "postgres://carol@local/other"},
+			want: "postgres://carol@local/other",
+		},
+		{
+			name: "env namespace keeps file url",
+			file: "url: postgres://bob:pw@remote:5432/graph\n",
+			env:  map[string]string{"SST_DB_NAMESPACE": "teamA"},
+			want: "postgres://bob:pw@remote:5432/graph",
+		},
+	}
+
+	for _,test := range tests {
+
+		t.Run(test.name,func(t *testing.T) {
+
+			home := t.TempDir()
+			t.Setenv("HOME",home)
+
+			for _,name := range []string{"SST_DB_HOST","SST_DB_PORT","SST_DB_USER","SST_DB_PASSWORD","SST_DB_NAME","SST_DB_SSLMODE","SST_DB_URL","SST_DB_NAMESPACE"} {
+				t.Setenv(name,test.env[name])
+			}
+
+			if len(test.file) > 0 {
+				if err := os.WriteFile(filepath.Join(home,CREDENTIALS_FILE),[]byte(test.file),0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := DBConnString(GetDBConfig()); got != test.want {
+				t.Errorf("got %q, want %q",got,test.want)
+			}
+		})
+	}
+}
+
+// **************************************************************************
+
+func TestDBConfigFromURL(t *testing.T) {
+
+	var cfg DBConfig
+
+	cfg.User = "kept"
+	cfg.DBName = "kept"
+	cfg.URL = "postgres://remote:6000"
+
+	cfg = DBConfigFromURL(cfg)
+
+	if cfg.Host != "remote" || cfg.Port != "6000" || cfg.User != "kept" || cfg.DBName != "kept" || len(cfg.URL) > 0 || len(cfg.Options) > 0 {
+		t.Errorf("got %+v",cfg)
+	}
+
+	cfg.URL = "postgres://remote/db?sslmode=require&target_session_attrs=read-write&sslcert=/a/b"
+
+	if got := DBConfigFromURL(cfg); got.SSLMode != "require" || got.Options != "sslcert=/a/b target_session_attrs=read-write" {
+		t.Errorf("query parameters became %q and %q",got.SSLMode,got.Options)
+	}
+
+	cfg.URL = "host=remote dbname=other"
+
+	if got := DBConfigFromURL(cfg); got != cfg {
+		t.Errorf("a key=value string was split into %+v",got)
+	}
+}
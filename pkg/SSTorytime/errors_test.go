@@ -0,0 +1,51 @@
+package SSTorytime
+
+import (
+	"errors"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestAPISentinelErrors(t *testing.T) {
+
+	sst := StorageTestGraph(t)
+
+	start := Vertex(sst,"start","house")
+	door := Vertex(sst,"door","house")
+	then,_ := GetDBArrowsWithArrowName(sst,"then")
+
+	_,_,unknown := EdgeErr(sst,start,"opens",door,nil,1)
+	_,_,empty := GetDBArrowsWithArrowNameErr(sst,"!")
+	_,hub_unknown := HubJoinErr(sst,"","",[]NodePtr{start.NPtr},"opens",nil,nil)
+	_,_,bad_arrow := DefineArrowErr(sst,EXPRESS+1,"a","b","c","d")
+	_,bad_link := AppendDBLinkToNodeErr(sst,start.NPtr,Link{Arr: then,Dst: door.NPtr,Wgt: 1},-EXPRESS-1)
+	_,hub_empty := HubJoinErr(sst,"","",nil,"then",nil,nil)
+	_,hub_weights := HubJoinErr(sst,"","",[]NodePtr{start.NPtr},"then",nil,[]float32{1,2})
+
+	tests := []struct{ name string; err error; want error }{
+		{"unknown arrow",unknown,ErrNoSuchArrow},
+		{"empty arrow name",empty,ErrNoSuchArrow},
+		{"hub with unknown arrow",hub_unknown,ErrNoSuchArrow},
+		{"arrow type out of bounds",bad_arrow,ErrSTOutOfBounds},
+		{"link type out of bounds",bad_link,ErrSTOutOfBounds},
+		{"self loop",IdempDBAddLinkErr(sst,door,Link{Arr: then,Wgt: 1},door),ErrSelfLoop},
+		{"zero weight",IdempDBAddLinkErr(sst,start,Link{Arr: then},door),ErrZeroWeight},
+		{"undefined arrow pointer",IdempDBAddLinkErr(sst,start,Link{Arr: 999,Wgt: 1},door),ErrNoSuchArrow},
+		{"hub without nodes",hub_empty,ErrBadHubJoin},
+		{"hub weights mismatch",hub_weights,ErrBadHubJoin},
+		{"no arrows at all",IdempDBAddLinkErr(OpenMemory(),start,Link{Arr: 0,Wgt: 1},door),ErrNoArrows},
+	}
+
+	for _,tt := range tests {
+		if !errors.Is(tt.err,tt.want) {
+			t.Errorf("%s: got %v, want %v",tt.name,tt.err,tt.want)
+		}
+	}
+
+	// The sentinels are distinct, so callers can tell them apart
+
+	if errors.Is(ErrNoSuchArrow,ErrNoArrows) || errors.Is(ErrSelfLoop,ErrZeroWeight) {
+		t.Errorf("sentinel errors match each other")
+	}
+}
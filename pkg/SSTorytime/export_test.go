@@ -0,0 +1,136 @@
+package SSTorytime
+
+import (
+	"testing"
+)
+
+// **************************************************************************
+
+func TestN4LText(t *testing.T) {
+
+	tests := []struct{ in,want string }{
+		{"plain text","plain text"},
+		{"say \"hello\" twice","say \"hello\" twice"},
+		{"f(x)","\"f(x)\""},
+		{"see #3","\"see #3\""},
+		{"http://x","\"http://x\""},
+		{"two\nlines","\"two\nlines\""},
+		{"say \"f(x) # 1\" twice","say \"f(x) # 1\" twice"},
+		{"odd \" quote","'odd \" quote'"},
+		{"it's (mine)","\"it's (mine)\""},
+		{"it's \"f(x)\"","it's \"f(x)\""},
+		{"$5 fee","$5 fee"},
+		{"$ sudo su -\n$ psql","\"$ sudo su -\n$ psql\""},
+		{"'tis (so)","\"'tis (so)\""},
+
+		// Not possible to write, as these are dittos, comments,
+		// references or contexts whether quoted or not
+
+		{" spaced"," spaced"},
+		{"#3 on the list","#3 on the list"},
+		{"-minus","-minus"},
+		{"$x.1 fee","$x.1 fee"},
+		{"\"quoted\" (first)","\"quoted\" (first)"},
+	}
+
+	for _,tt := range tests {
+		if got := N4LText(tt.in); got != tt.want {
+			t.Errorf("N4LText(%q) = %s, want %s",tt.in,got,tt.want)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestFormatN4LChapter(t *testing.T) {
+
+	sst,fwd := ExportTestSession()
+	then := InsertArrowDirectory(sst,"leadsto","then","then","+")
+	InsertInverseArrowDirectory(sst,then,InsertArrowDirectory(sst,"leadsto","prior","prior","-"))
+
+	xy := map[string]bool{"x":true,"y":true}
+	z := map[string]bool{"z":true}
+
+	a := ExportTestNode(sst,"alpha",xy)
+	b := ExportTestNode(sst,"beta",xy)
+	c := ExportTestNode(sst,"gamma (g)",xy)
+	d := ExportTestNode(sst,"delta",z)
+
+	// alpha (fwd) beta (fwd,0.5, extra) gamma (g), then a sequence and a
+	// link that no line accounts for
+
+	ab := ExportTestLink(sst,a,fwd,1,xy,nil,b)
+	bc := ExportTestLink(sst,b,fwd,0.5,xy,[]string{"extra"},c)
+	ExportTestLink(sst,c,then,1,xy,nil,a)
+	ExportTestLink(sst,a,fwd,1,z,nil,d)
+
+	sst.PageMap = append(sst.PageMap,PageMap{"notes","start",RegisterContext(sst,xy,nil),1,[]Link{{Dst: a},ab,bc}})
+	sst.PageMap = append(sst.PageMap,PageMap{"notes","",RegisterContext(sst,xy,nil),2,[]Link{{Dst: a}}})
+
+	want := `-notes
+
+:: x, y ::
+
+@start alpha (fwd) beta (fwd,0.5, extra) "gamma (g)"
+   "
+"gamma (g)" (then) alpha
+
+:: z ::
+
+alpha (fwd) delta
+`
+
+	if got := FormatN4LChapter(sst,GetMemoryChapterExport(sst,"notes")); got != want {
+		t.Errorf("got\n%s\nwant\n%s",got,want)
+	}
+}
+
+// **************************************************************************
+// Helpers, as N4L would do it
+// **************************************************************************
+
+func ExportTestSession() (PoSST,ArrowPtr) {
+
+	// A memory session with the any context, the internal empty/void
+	// arrows, and fwd/bwd to link with
+
+	sst := OpenMemory()
+
+	RegisterContext(sst,nil,[]string{"any"})
+
+	empty := InsertArrowDirectory(sst,"leadsto","empty","debug","+")
+	InsertInverseArrowDirectory(sst,empty,InsertArrowDirectory(sst,"leadsto","void","unbug","-"))
+	fwd := InsertArrowDirectory(sst,"leadsto","fwd","leads to","+")
+	InsertInverseArrowDirectory(sst,fwd,InsertArrowDirectory(sst,"leadsto","bwd","comes from","-"))
+
+	return sst,fwd
+}
+
+// **************************************************************************
+
+func ExportTestNode(sst PoSST,s string,ctx map[string]bool) NodePtr {
+
+	var n Node
+
+	n.S = s
+	n.L,n.NPtr.Class = StorageClass(s)
+	n.Chap = "notes"
+
+	nptr := AppendTextToDirectory(sst,n,func(string){})
+	AppendLinkToNode(sst,nptr,Link{Arr: 0, Wgt: 1, Ctx: RegisterContext(sst,ctx,nil)},NodePtr{})
+
+	return nptr
+}
+
+// **************************************************************************
+
+func ExportTestLink(sst PoSST,from NodePtr,arr ArrowPtr,wgt float32,ctx map[string]bool,extra []string,to NodePtr) Link {
+
+	link := Link{Arr: arr, Wgt: wgt, Ctx: RegisterContext(sst,ctx,extra), Dst: to}
+	AppendLinkToNode(sst,from,link,to)
+
+	inv := Link{Arr: GetInverseArrow(sst,arr), Wgt: 1, Ctx: RegisterContext(sst,ctx,nil), Dst: from}
+	AppendLinkToNode(sst,to,inv,from)
+
+	return link
+}
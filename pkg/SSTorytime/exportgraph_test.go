@@ -0,0 +1,92 @@
+package SSTorytime
+
+import (
+	"strings"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestExportGraphFormats(t *testing.T) {
+
+	sst,fwd := ExportTestSession()
+
+	ctx := map[string]bool{"x":true}
+
+	a := ExportTestNode(sst,"A & \"B\"",ctx)
+	b := ExportTestNode(sst,"beta",ctx)
+	c := ExportTestNode(sst,"outside",ctx)
+
+	ExportTestLink(sst,a,fwd,0.5,ctx,nil,b)
+	ExportTestLink(sst,b,fwd,1,ctx,nil,c)
+
+	// Only a and b are selected, and the inverse link on b must not
+	// show up as a second edge
+
+	g := NewExportGraph()
+	AddExportNode(&g,GetMemoryNodeFromPtr(sst,a))
+	AddExportNode(&g,GetMemoryNodeFromPtr(sst,b))
+	AddExportNodeLinks(sst,&g)
+
+	if len(g.Nodes) != 2 || len(g.Edges) != 1 {
+		t.Fatalf("got %d nodes and %d edges, want 2 and 1",len(g.Nodes),len(g.Edges))
+	}
+
+	want := map[string][]string{
+		"graphml": {
+			`<node id="n3_0">`,
+			`<data key="text">A &amp; &#34;B&#34;</data>`,
+			`<edge id="e0" source="n3_0" target="n1_0">`,
+			`<data key="short">fwd</data>`,
+			`<data key="sttype">1</data>`,
+			`<data key="weight">0.5</data>`,
+			`<data key="econtext">x</data>`,
+		},
+		"gexf": {
+			`<node id="n3_0" label="A &amp; &#34;B&#34;">`,
+			`<edge id="e0" source="n3_0" target="n1_0" label="fwd" weight="0.5">`,
+			`<attvalue for="0" value="leads to"/>`,
+		},
+		"dot": {
+			`n3_0 [label="A & \"B\"", chapter="notes", context="x"];`,
+			`n3_0 -> n1_0 [label="fwd", arrow="leads to", sttype=1, stname="+leads to", weight=0.5, context="x"];`,
+		},
+	}
+
+	for format,lines := range want {
+
+		text,err := FormatExportGraph(sst,g,format)
+
+		if err != nil {
+			t.Fatalf("%s: %v",format,err)
+		}
+
+		for _,line := range lines {
+			if !strings.Contains(text,line) {
+				t.Errorf("%s output is missing %s\n%s",format,line,text)
+			}
+		}
+	}
+
+	if _,err := FormatExportGraph(sst,g,"png"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+// **************************************************************************
+
+func TestExportGraphFormat(t *testing.T) {
+
+	tests := []struct{ in,want string }{
+		{"map.graphml","graphml"},
+		{"out/Map.GEXF","gexf"},
+		{"map.dot","dot"},
+		{"map","",},
+	}
+
+	for _,tt := range tests {
+		if got := ExportGraphFormat(tt.in); got != tt.want {
+			t.Errorf("ExportGraphFormat(%q) = %q, want %q",tt.in,got,tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package SSTorytime
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// **************************************************************************
+
+func ExportTestRDFGraph() (PoSST,ExportGraph) {
+
+	sst,fwd := ExportTestSession()
+
+	ctx := map[string]bool{"x":true}
+
+	a := ExportTestNode(sst,"say \"hi\"",ctx)
+	b := ExportTestNode(sst,"beta",ctx)
+
+	ExportTestLink(sst,a,fwd,0.5,ctx,nil,b)
+
+	g := NewExportGraph()
+	AddExportNode(&g,GetMemoryNodeFromPtr(sst,a))
+	AddExportNode(&g,GetMemoryNodeFromPtr(sst,b))
+	AddExportNodeLinks(sst,&g)
+
+	return sst,g
+}
+
+// **************************************************************************
+
+func TestRDFNTriples(t *testing.T) {
+
+	sst,g := ExportTestRDFGraph()
+
+	text,err := FormatRDF(sst,g,"","nt")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`<urn:sstorytime:node/2_0> <http://www.w3.org/2000/01/rdf-schema#label> "say \"hi\"" .`,
+		`<urn:sstorytime:node/2_0> <urn:sstorytime:arrow/fwd> <urn:sstorytime:node/1_0> .`,
+		`<urn:sstorytime:arrow/fwd> <http://www.w3.org/2002/07/owl#inverseOf> <urn:sstorytime:arrow/bwd> .`,
+		`<urn:sstorytime:arrow/bwd> <urn:sstorytime:vocab#stType> "-1"^^<http://www.w3.org/2001/XMLSchema#integer> .`,
+		`<urn:sstorytime:arrow/fwd> <urn:sstorytime:vocab#stClass> <urn:sstorytime:vocab#LEADSTO> .`,
+		`_:link0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#predicate> <urn:sstorytime:arrow/fwd> .`,
+		`_:link0 <urn:sstorytime:vocab#weight> "0.5"^^<http://www.w3.org/2001/XMLSchema#float> .`,
+		`_:link0 <urn:sstorytime:vocab#context> "x" .`,
+	}
+
+	for _,line := range want {
+		if !strings.Contains(text,line+"\n") {
+			t.Errorf("missing %s\n%s",line,text)
+		}
+	}
+
+	// The inverse link stored on beta is the same statement
+
+	if n := strings.Count(text,"rdf-syntax-ns#Statement>"); n != 1 {
+		t.Errorf("got %d statements, want 1",n)
+	}
+}
+
+// **************************************************************************
+
+func TestRDFTurtle(t *testing.T) {
+
+	sst,g := ExportTestRDFGraph()
+
+	text,err := FormatRDF(sst,g,"http://example.org/kb/","turtle")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"@prefix sst: <urn:sstorytime:vocab#> .",
+		"<http://example.org/kb/node/2_0>\n    a sst:Node ;\n    rdfs:label \"say \\\"hi\\\"\" ;",
+		"    sst:weight \"0.5\"^^xsd:float ;",
+		"    owl:inverseOf <http://example.org/kb/arrow/bwd> .",
+	}
+
+	for _,line := range want {
+		if !strings.Contains(text,line) {
+			t.Errorf("missing %s\n%s",line,text)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestRDFJSONLD(t *testing.T) {
+
+	sst,g := ExportTestRDFGraph()
+
+	text,err := FormatRDF(sst,g,"","jsonld")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Graph []map[string]interface{} `json:"@graph"`
+	}
+
+	if err := json.Unmarshal([]byte(text),&doc); err != nil {
+		t.Fatalf("not JSON: %v\n%s",err,text)
+	}
+
+	var found bool
+
+	for _,obj := range doc.Graph {
+		if obj["@id"] == "_:link0" {
+			found = true
+			w := obj["sst:weight"].([]interface{})[0].(map[string]interface{})
+			if w["@value"] != "0.5" || w["@type"] != "xsd:float" {
+				t.Errorf("weight is %v",w)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("no reified statement in\n%s",text)
+	}
+}
@@ -2,4 +2,7 @@ module SSTorytime
 
 go 1.24.2
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/lib/pq v1.10.9
+	golang.org/x/text v0.24.0
+)
@@ -0,0 +1,73 @@
+package SSTorytime
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestGraphFileRoundTrip(t *testing.T) {
+
+	sst := StorageTestGraph(t)
+	file := filepath.Join(t.TempDir(),"notes.sstg")
+
+	if err := WriteGraphFileErr(sst,file); err != nil {
+		t.Fatal(err)
+	}
+
+	read,err := OpenGraphFileErr(file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(GetMemoryNodes(read),GetMemoryNodes(sst)) {
+		t.Errorf("nodes differ after reading back")
+	}
+
+	if !reflect.DeepEqual(read.ArrowDirectory,sst.ArrowDirectory) || !reflect.DeepEqual(read.InverseArrows,sst.InverseArrows) {
+		t.Errorf("arrows differ after reading back")
+	}
+
+	if !reflect.DeepEqual(read.ContextDirectory,sst.ContextDirectory) || !reflect.DeepEqual(read.PageMap,sst.PageMap) {
+		t.Errorf("contexts or page map differ after reading back")
+	}
+
+	// Searches work as before, and new nodes don't overwrite old ones
+
+	start := GetDBNodePtrMatchingName(read,"start","")
+
+	if paths,_ := GetFwdPathsAsLinks(read,start[0],LEADSTO,3,10); len(paths) != 2 {
+		t.Errorf("got %d forward paths, want 2",len(paths))
+	}
+
+	if n := Vertex(read,"start","house"); n.NPtr != start[0] {
+		t.Errorf("start was added again as %v",n.NPtr)
+	}
+
+	if n := Vertex(read,"window","house"); n.NPtr.CPtr != 4 {
+		t.Errorf("window got %v, want the next free pointer",n.NPtr)
+	}
+}
+
+// **************************************************************************
+
+func TestGraphFileErrors(t *testing.T) {
+
+	dir := t.TempDir()
+	notes := filepath.Join(dir,"notes.n4l")
+
+	if err := os.WriteFile(notes,[]byte("-notes\n a (then) b\n"),0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _,file := range []string{notes,filepath.Join(dir,"nosuch.sstg")} {
+		if _,err := OpenGraphFileErr(file); !errors.Is(err,ErrGraphFile) {
+			t.Errorf("%s: got %v, want ErrGraphFile",filepath.Base(file),err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package SSTorytime
+
+import (
+	"testing"
+)
+
+// **************************************************************************
+
+func TestNodeID(t *testing.T) {
+
+	id := NodeID("","Greek letter  Alpha")
+
+	if len(id) != NODE_ID_LENGTH || id != NodeID(""," Greek letter\tAlpha\n") {
+		t.Errorf("spacing changed the ID %s",id)
+	}
+
+	if id == NodeID("","greek letter alpha") || id == NodeID("teamA","Greek letter Alpha") {
+		t.Errorf("capitals and namespace should give other IDs")
+	}
+
+	if got,ok := IsNodeIDLiteral(" "+NodeIDLiteral(id)+" "); !ok || got != id {
+		t.Errorf("literal %s read as %q %v",NodeIDLiteral(id),got,ok)
+	}
+
+	for _,s := range []string{"(1,2)","(#xyz)","#"+id,"(gate)"} {
+		if _,ok := IsNodeIDLiteral(s); ok {
+			t.Errorf("%s isn't an ID literal",s)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestNodeIDSearch(t *testing.T) {
+
+	// An ID survives a rebuild in another order, where the NPtr doesn't
+
+	sst := StorageTestGraph(t)
+	gate := GetDBNodePtrMatchingName(sst,"gate","")[0]
+	id := GetNodeID(sst,gate)
+
+	other := OpenMemory()
+	Vertex(other,"gate","garden")
+
+	if other_gate := GetNodePtrsByID(other,id); len(other_gate) != 1 || other_gate[0] == gate {
+		t.Errorf("got %v, want one node numbered differently from %v",other_gate,gate)
+	}
+
+	search := DecodeSearchField("\\from "+NodeIDLiteral(id)+" (1,2)")
+	nptrs := SolveNodePtrs(sst,search.From,search,nil,10)
+
+	if len(nptrs) != 2 {
+		t.Fatalf("got %v, want gate and (1,2)",nptrs)
+	}
+
+	for _,nptr := range nptrs {
+		if nptr != gate && nptr != (NodePtr{Class: 1,CPtr: 2}) {
+			t.Errorf("unexpected %v",nptr)
+		}
+	}
+
+	if event := JSONNodeEvent(sst,gate,Coords{},[ST_TOP][]Orbit{}); event.ID != id {
+		t.Errorf("node event has ID %q, want %q",event.ID,id)
+	}
+}
@@ -0,0 +1,167 @@
+package SSTorytime
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+// **************************************************************************
+// Search text from the web/CLI must only ever reach the database as
+// query parameters, never as part of the SQL text
+// **************************************************************************
+
+var hostile_searches = []string{
+	`'; DROP TABLE Node; --`,
+	`robert'); drop table node;-- in chapter o'reilly`,
+	`name x' OR '1'='1 chapter y' OR '1'='1 context z'}',"a"`,
+	`(caf\'e) \\x`,
+	`!o'brien! chapter (l'été) context ((')),"\\"`,
+	`name "$1" chapter "$$;select 1;$$" context "{\"}"`,
+}
+
+// **************************************************************************
+
+func TestNodeWhereStringKeepsSearchTextOutOfSQL(t *testing.T) {
+
+	sst := OpenMemory()
+
+	for _,cmd := range hostile_searches {
+
+		search := DecodeSearchField(cmd)
+
+		names := search.Name
+
+		if len(names) == 0 {
+			names = []string{cmd}
+		}
+
+		for _,name := range names {
+
+			var args []interface{}
+
+			where := NodeWhereString(sst,&args,name,search.Chapter,search.Context,nil,search.Sequence)
+
+			CheckQueryText(t,cmd,where)
+
+			if len(args) == 0 {
+				t.Errorf("%q: no query parameters in %q",cmd,where)
+			}
+		}
+
+		// And all the way through, with a hostile chapter and context too
+
+		var args []interface{}
+
+		where := NodeWhereString(sst,&args,cmd,cmd,[]string{cmd,cmd},nil,false)
+
+		CheckQueryText(t,cmd,where)
+
+		if !ArgsContain(args,cmd) {
+			t.Errorf("%q: search text missing from query parameters %v",cmd,args)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestIsBracketedSearchTermDoesNotEscape(t *testing.T) {
+
+	// Escaping is now the driver's job, so values must arrive verbatim
+
+	accents,stripped := IsBracketedSearchTerm("( o'reilly )")
+
+	if !accents || stripped != "o'reilly" {
+		t.Errorf("got %v %q, want true \"o'reilly\"",accents,stripped)
+	}
+}
+
+// **************************************************************************
+
+func TestSQLStringArrayIsNeverNull(t *testing.T) {
+
+	for _,list := range [][]string{nil,{},{""},{"b","","a'"}} {
+
+		value,err := SQLStringArray(list).(driver.Valuer).Value()
+
+		if err != nil || value == nil {
+			t.Errorf("%q: got %v %v, want a non-NULL array",list,value,err)
+		}
+	}
+
+	value,_ := SQLStringArray([]string{"b","","a'"}).(driver.Valuer).Value()
+
+	if value != `{"a'","b"}` {
+		t.Errorf("got %v, want sorted and without empty entries",value)
+	}
+}
+
+// **************************************************************************
+
+func TestSolveNodePtrsWithHostileSearch(t *testing.T) {
+
+	sst,err := OpenErr(false)
+
+	if err != nil {
+		t.Skip("no database available:",err)
+	}
+
+	defer Close(sst)
+
+	for _,cmd := range hostile_searches {
+
+		search := DecodeSearchField(cmd)
+
+		names := append(search.Name,cmd)
+		search.Chapter = cmd
+		search.Context = append(search.Context,cmd)
+
+		// Should quietly find nothing, not fail or run anything
+
+		SolveNodePtrs(sst,names,search,nil,10)
+		GetDBChaptersMatchingName(sst,cmd)
+		GetDBContextByName(sst,cmd)
+		GetDBPageMap(sst,cmd,search.Context,1)
+	}
+
+	var count int
+
+	err = sst.DB.QueryRow("SELECT count(*) FROM Node").Scan(&count)
+
+	if err != nil {
+		t.Fatalf("Node table damaged by hostile search: %v",err)
+	}
+}
+
+// **************************************************************************
+// Helpers
+// **************************************************************************
+
+func CheckQueryText(t *testing.T,cmd,where string) {
+
+	t.Helper()
+
+	// The only quoted literal we generate ourselves
+
+	text := strings.ReplaceAll(where,"'english'","")
+
+	for _,bad := range []string{"'",";","--","\\","\""} {
+		if strings.Contains(text,bad) {
+			t.Errorf("%q: search text leaked into SQL %q",cmd,where)
+			return
+		}
+	}
+}
+
+// **************************************************************************
+
+func ArgsContain(args []interface{},s string) bool {
+
+	for _,a := range args {
+		if str,ok := a.(string); ok && strings.Contains(str,s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,150 @@
+package SSTorytime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestMigrationsAreOrdered(t *testing.T) {
+
+	err := CheckMigrations(MIGRATIONS)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if MIGRATIONS[0].Version != 1 || MIGRATIONS[0].Statements != nil {
+		t.Errorf("the first migration must be the version 1 baseline, got %+v",MIGRATIONS[0])
+	}
+}
+
+// **************************************************************************
+
+func TestCheckMigrationsRejectsDisorder(t *testing.T) {
+
+	bad := [][]Migration{
+		{{Version: 0}},
+		{{Version: 1},{Version: 1}},
+		{{Version: 1},{Version: 3},{Version: 2}},
+	}
+
+	for _,list := range bad {
+		if err := CheckMigrations(list); !errors.Is(err,ErrMigration) {
+			t.Errorf("%+v: got %v, want ErrMigration",list,err)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestMigrationsAfter(t *testing.T) {
+
+	pending,err := MigrationsAfter(0)
+
+	if err != nil || len(pending) != len(MIGRATIONS) {
+		t.Errorf("from version 0: got %d pending, %v",len(pending),err)
+	}
+
+	pending,err = MigrationsAfter(LatestSchemaVersion())
+
+	if err != nil || len(pending) != 0 {
+		t.Errorf("from latest: got %d pending, %v",len(pending),err)
+	}
+
+	_,err = MigrationsAfter(LatestSchemaVersion()+1)
+
+	if !errors.Is(err,ErrMigration) {
+		t.Errorf("database newer than program: got %v, want ErrMigration",err)
+	}
+}
+
+// **************************************************************************
+
+func TestMigrateHashesIDsInNamespace(t *testing.T) {
+
+	// A version 2 database migrated with a namespace must have the IDs
+	// that NodeID() gives in that namespace, now and when opened again
+
+	cfg := GetDBConfig()
+	cfg.Namespace = ""
+
+	admin,err := OpenWithConfigErr(cfg,false)
+
+	if err != nil {
+		t.Skip("no database available:",err)
+	}
+
+	defer Close(admin)
+
+	scratch := fmt.Sprintf("sst_migrate_test_%d",os.Getpid())
+
+	if _,err = admin.DB.Exec("CREATE DATABASE "+scratch); err != nil {
+		t.Skip("can't create a scratch database:",err)
+	}
+
+	defer admin.DB.Exec("DROP DATABASE IF EXISTS "+scratch+" WITH (FORCE)")
+
+	cfg.DBName = scratch
+
+	// Create it as version 2
+
+	latest := MIGRATIONS
+	MIGRATIONS = latest[:2]
+	old,err := OpenWithConfigErr(cfg,false)
+	MIGRATIONS = latest
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := []string{"apple","pear  tree","  plum\t"}
+
+	for i,text := range texts {
+		_,err = old.DB.Exec("INSERT INTO Node (NPtr,L,S,Chap,Seq) VALUES ((1,$1::int),$2::int,$3,'fruit',false)",i,len(text),text)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Close(old)
+
+	cfg.Namespace = "orchard"
+
+	for _,step := range []string{"migrating","reopened"} {
+
+		sst,err := OpenWithConfigErr(cfg,false)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if step == "migrating" {
+			if _,err = MigrateErr(sst); err != nil {
+				Close(sst)
+				t.Fatal(err)
+			}
+		}
+
+		if sst.Namespace != "orchard" {
+			t.Errorf("%s: namespace %q, want \"orchard\"",step,sst.Namespace)
+		}
+
+		for _,text := range texts {
+
+			var id string
+
+			if err = sst.DB.QueryRow("SELECT ID FROM Node WHERE S=$1",text).Scan(&id); err != nil {
+				t.Errorf("%s: %q: %v",step,text,err)
+			} else if id != NodeID(sst.Namespace,text) {
+				t.Errorf("%s: %q has ID %s, want %s",step,text,id,NodeID(sst.Namespace,text))
+			}
+		}
+
+		Close(sst)
+	}
+}
@@ -0,0 +1,125 @@
+package SSTorytime
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestSessionNgramTablesSideBySide(t *testing.T) {
+
+	// Run with go test -race: each session counts its own text, and opening
+	// another session meanwhile must not reset either of them
+
+	dir := t.TempDir()
+	apples := filepath.Join(dir,"apples.txt")
+	pears := filepath.Join(dir,"pears.txt")
+
+	if err := os.WriteFile(apples,[]byte("red apples grow here. red apples grow there. red apples fall.\n"),0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(pears,[]byte("green pears ripen late. green pears ripen slowly.\n"),0644); err != nil {
+		t.Fatal(err)
+	}
+
+	one := OpenMemory()
+	two := OpenMemory()
+
+	var wg sync.WaitGroup
+
+	for _,run := range []struct{ sst PoSST; file string }{{one,apples},{two,pears},{one,apples},{two,pears}} {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			SessionTestReadText(run.sst,run.file)
+		}()
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			OpenMemory()
+		}()
+	}
+
+	wg.Wait()
+
+	// The same reading, one at a time
+
+	want_one := OpenMemory()
+	want_two := OpenMemory()
+
+	for i := 0; i < 2; i++ {
+		SessionTestReadText(want_one,apples)
+		SessionTestReadText(want_two,pears)
+	}
+
+	if len(want_one.STMNgramFreq[2]) == 0 || len(want_two.STMNgramFreq[2]) == 0 {
+		t.Fatalf("the test texts gave no bigrams")
+	}
+
+	if !reflect.DeepEqual(one.STMNgramFreq,want_one.STMNgramFreq) || !reflect.DeepEqual(one.STMNgramLast,want_one.STMNgramLast) {
+		t.Errorf("first session has %v, want %v",one.STMNgramFreq,want_one.STMNgramFreq)
+	}
+
+	if !reflect.DeepEqual(two.STMNgramFreq,want_two.STMNgramFreq) || !reflect.DeepEqual(two.STMNgramLast,want_two.STMNgramLast) {
+		t.Errorf("second session has %v, want %v",two.STMNgramFreq,want_two.STMNgramFreq)
+	}
+
+	if len(OpenMemory().STMNgramLoca[2]) != 0 {
+		t.Errorf("a new session starts with n-grams from another")
+	}
+}
+
+// **************************************************************************
+
+func TestNewSessionKeepsNamespaceAndStore(t *testing.T) {
+
+	// Node IDs in the fresh session must hash as in the one it came from
+
+	sst,err := OpenErr(false)
+
+	if err != nil {
+		t.Skip("no database available:",err)
+	}
+
+	defer Close(sst)
+
+	sst.Namespace = "session-test"
+
+	fresh,err := NewSessionErr(sst)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fresh.Namespace != sst.Namespace || fresh.Store != sst.Store {
+		t.Errorf("fresh session has namespace %q and store %v, want %q and %v",fresh.Namespace,fresh.Store,sst.Namespace,sst.Store)
+	}
+
+	if fresh.GraphSession == sst.GraphSession {
+		t.Errorf("fresh session shares the graph in memory")
+	}
+}
+
+// **************************************************************************
+
+func SessionTestReadText(sst PoSST,file string) {
+
+	psf,_ := FractionateTextFile(sst,file)
+
+	for p := range psf {
+		for s := range psf[p] {
+			for f := range psf[p][s] {
+				RunningIntentionality(sst,s+1,psf[p][s][f])
+			}
+		}
+	}
+}
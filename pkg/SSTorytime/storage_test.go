@@ -0,0 +1,168 @@
+package SSTorytime
+
+import (
+	"errors"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestMemoryStorageSearch(t *testing.T) {
+
+	sst := StorageTestGraph(t)
+
+	nptrs := GetDBNodePtrMatchingNCCS(sst,"door","any",nil,nil,false,10)
+
+	if len(nptrs) != 2 || GetDBNodeByNodePtr(sst,nptrs[0]).S != "door" {
+		t.Errorf("door matched %v, want door then doors",nptrs)
+	}
+
+	if got := GetDBNodePtrMatchingNCCS(sst,"gate","gard",[]string{"garden"},nil,false,10); len(got) != 1 {
+		t.Errorf("gate in garden/garden matched %v",got)
+	}
+
+	if got := GetDBNodePtrMatchingNCCS(sst,"gate","any",[]string{"inside"},nil,false,10); len(got) != 0 {
+		t.Errorf("gate in context inside matched %v",got)
+	}
+
+	if got := GetDBChaptersMatchingName(sst,"gard"); len(got) != 1 || got[0] != "garden" {
+		t.Errorf("chapters %v, want garden",got)
+	}
+
+	start := GetDBNodePtrMatchingName(sst,"start","")[0]
+
+	paths,_ := GetFwdPathsAsLinks(sst,start,LEADSTO,3,10)
+
+	if len(paths) != 2 {
+		t.Errorf("got %d forward paths, want 2",len(paths))
+	}
+
+	paths,_ = GetEntireNCConePathsAsLinks(sst,"fwd",start,3,"house",[]string{"outside"},10)
+
+	if len(paths) != 1 || len(paths[0]) != 3 {
+		t.Errorf("got %v, want start, door and doors",paths)
+	}
+}
+
+// **************************************************************************
+
+func TestMemoryStorageNotes(t *testing.T) {
+
+	sst := StorageTestGraph(t)
+
+	if pm := GetDBPageMap(sst,"hous",[]string{"outside"},1); len(pm) != 1 || pm[0].Line != 3 {
+		t.Errorf("page map %v, want line 3 of house",pm)
+	}
+
+	then,_ := GetDBArrowsWithArrowName(sst,"then")
+	apps := GetAppointedNodesByArrow(sst,then,nil,"any",2)
+
+	if len(apps) != 1 {
+		t.Fatalf("appointments %v",apps)
+	}
+
+	for _,list := range apps {
+		if len(list) != 1 || GetDBNodeByNodePtr(sst,list[0].NTo).S != "doors" || len(list[0].NFrom) != 2 {
+			t.Errorf("doors should be appointed by door and gate, got %v",list)
+		}
+	}
+
+	if err := DeleteChapterErr(sst,"garden"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := GetDBNodePtrMatchingName(sst,"gate",""); len(got) != 0 {
+		t.Errorf("gate survived its chapter")
+	}
+
+	if got := GetDBNodePtrMatchingName(sst,"doors",""); len(got) != 1 || GetDBNodeByNodePtr(sst,got[0]).Chap != "house" {
+		t.Errorf("shared node should only lose the chapter, got %v",got)
+	}
+
+	if err := DeleteChapterErr(sst,"garden"); !errors.Is(err,ErrNoSuchChapter) {
+		t.Errorf("got %v, want ErrNoSuchChapter",err)
+	}
+}
+
+// **************************************************************************
+
+func TestMemoryStorageLastSeen(t *testing.T) {
+
+	sst := StorageTestGraph(t)
+	door := GetDBNodePtrMatchingName(sst,"door","")[0]
+
+	UpdateLastSawNPtr(sst,door.Class,int(door.CPtr),"door")
+	UpdateLastSawNPtr(sst,door.Class,int(door.CPtr),"door")
+
+	if ls := GetLastSawNPtr(sst,door); ls.Freq != 1 || ls.NPtr != door {
+		t.Errorf("seen twice within a minute should count once, got %v",ls)
+	}
+
+	UpdateLastSawSection(sst,"house")
+
+	if got := GetLastSawSection(sst); len(got) != 2 || got[1].Section != "house" {
+		t.Errorf("sections %v",got)
+	}
+}
+
+// **************************************************************************
+
+func TestMemoryMatchContext(t *testing.T) {
+
+	sst := OpenMemory()
+	ctx := TryContext(sst,[]string{"outside.dark","garden"})
+
+	tests := []struct{ user []string; want bool }{
+		{nil,true},
+		{[]string{"garden"},true},
+		{[]string{"gard"},true},
+		{[]string{"outside"},false},
+		{[]string{"dark","outside"},true},
+		{[]string{"inside"},false},
+	}
+
+	for _,tt := range tests {
+		if got := MemoryMatchContext(sst,ctx,tt.user); got != tt.want {
+			t.Errorf("%v: got %v, want %v",tt.user,got,tt.want)
+		}
+	}
+}
+
+// **************************************************************************
+// Helpers
+// **************************************************************************
+
+func StorageTestGraph(t *testing.T) PoSST {
+
+	// start -> door -> doors <- gate, with door and doors in the house
+
+	sst := OpenMemory()
+
+	// Arrow 0 is the empty arrow in SSTconfig, which paths skip
+
+	if _,_,err := DefineArrowErr(sst,LEADSTO,"debug","empty","unbug","void"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _,_,err := DefineArrowErr(sst,LEADSTO,"then","then","from","from"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := Vertex(sst,"start","house")
+	door := Vertex(sst,"door","house")
+	doors := Vertex(sst,"doors","house")
+	gate := Vertex(sst,"gate","garden")
+
+	Edge(sst,start,"then",door,[]string{"outside"},1)
+	Edge(sst,door,"then",doors,[]string{"outside"},1)
+	Edge(sst,start,"then",gate,[]string{"gàrden"},1)
+	Edge(sst,gate,"then",doors,[]string{"garden"},1)
+
+	doors = Vertex(sst,"doors","garden")
+
+	sst.PageMap = append(sst.PageMap,
+		PageMap{Chapter: "house",Context: TryContext(sst,[]string{"outside"}),Line: 3},
+		PageMap{Chapter: "garden",Context: TryContext(sst,[]string{"garden"}),Line: 5})
+
+	return sst
+}
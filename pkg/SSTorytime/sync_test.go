@@ -0,0 +1,99 @@
+package SSTorytime
+
+import (
+	"reflect"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestSyncChapterList(t *testing.T) {
+
+	synced := map[string]bool{"notes":true, "more notes":true}
+
+	tests := []struct{ db,mem,want string }{
+		{"notes","notes","notes"},
+		{"other,notes","notes","other,notes"},
+		{"notes,other","more notes","other,more notes"},
+		{"other","notes","other,notes"},
+		{"","notes,more notes","notes,more notes"},
+		{"a, b,notes","notes","a, b,notes"},   // chapter names may contain ", "
+	}
+
+	for _,tt := range tests {
+		if got := SyncChapterList(tt.db,tt.mem,synced); got != tt.want {
+			t.Errorf("SyncChapterList(%q,%q) = %q, want %q",tt.db,tt.mem,got,tt.want)
+		}
+	}
+}
+
+// **************************************************************************
+
+func TestRemapLinks(t *testing.T) {
+
+	self := NodePtr{Class: 1, CPtr: 7}
+	remap := map[NodePtr]NodePtr{{1,100}: {1,3}, {2,101}: self}
+
+	in := []Link{
+		{Arr: 1, Wgt: 1, Ctx: 2, Dst: NodePtr{1,100}},
+		{Arr: 1, Wgt: 1, Ctx: 2, Dst: NodePtr{1,100}},  // duplicate
+		{Arr: 1, Wgt: 1, Ctx: 2, Dst: NodePtr{2,101}},  // becomes a self-loop
+		{Arr: 2, Wgt: 1, Ctx: 0, Dst: NodePtr{4,9}},    // not remapped
+	}
+
+	want := []Link{
+		{Arr: 1, Wgt: 1, Ctx: 2, Dst: NodePtr{1,3}},
+		{Arr: 2, Wgt: 1, Ctx: 0, Dst: NodePtr{4,9}},
+	}
+
+	if got := RemapLinks(in,remap,self); !reflect.DeepEqual(got,want) {
+		t.Errorf("got %v, want %v",got,want)
+	}
+}
+
+// **************************************************************************
+
+func TestSyncLinks(t *testing.T) {
+
+	owned := map[NodePtr]bool{{1,1}: true, {1,2}: true}
+
+	db := []Link{
+		{Arr: 5, Wgt: 1, Ctx: 0, Dst: NodePtr{1,1}},   // owned, kept by memory with new weight
+		{Arr: 5, Wgt: 1, Ctx: 0, Dst: NodePtr{1,2}},   // owned, gone from memory
+		{Arr: 6, Wgt: 1, Ctx: 0, Dst: NodePtr{3,9}},   // belongs to another chapter
+	}
+
+	mem := []Link{
+		{Arr: 5, Wgt: 0.5, Ctx: 0, Dst: NodePtr{1,1}},
+		{Arr: 7, Wgt: 1, Ctx: 0, Dst: NodePtr{1,4}},   // new
+	}
+
+	want := []Link{
+		{Arr: 5, Wgt: 0.5, Ctx: 0, Dst: NodePtr{1,1}},
+		{Arr: 6, Wgt: 1, Ctx: 0, Dst: NodePtr{3,9}},
+		{Arr: 7, Wgt: 1, Ctx: 0, Dst: NodePtr{1,4}},
+	}
+
+	got := SyncLinks(db,mem,owned)
+
+	if !reflect.DeepEqual(got,want) {
+		t.Errorf("got %v, want %v",got,want)
+	}
+
+	var a,b Node
+	a.I[ST_ZERO] = db
+	b.I[ST_ZERO] = got
+
+	if SameSyncNode(a,b) || !SameSyncNode(b,b) {
+		t.Errorf("SameSyncNode does not see the link changes")
+	}
+}
+
+// **************************************************************************
+
+func TestLikeEscape(t *testing.T) {
+
+	if got := LikeEscape(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("got %q",got)
+	}
+}
@@ -0,0 +1,66 @@
+package SSTorytime
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+// **************************************************************************
+
+func TestUniqueLinks(t *testing.T) {
+
+	self := NodePtr{Class: 1, CPtr: 1}
+
+	in := []Link{
+		{Arr: 3, Wgt: 1, Ctx: 0, Dst: NodePtr{1,2}},
+		{Arr: 3, Wgt: 1, Ctx: 0, Dst: self},
+		{Arr: 3, Wgt: 1, Ctx: 0, Dst: NodePtr{1,2}},
+		{Arr: 3, Wgt: 2, Ctx: 0, Dst: NodePtr{1,2}},
+	}
+
+	want := []Link{in[0],in[3]}
+
+	if got := UniqueLinks(in,self); !reflect.DeepEqual(got,want) {
+		t.Errorf("got %v, want %v",got,want)
+	}
+}
+
+// **************************************************************************
+
+func TestSQLLinkArrayRoundTrip(t *testing.T) {
+
+	// What we send as Link[] must read back through ParseLinkArray
+
+	links := []Link{
+		{Arr: 77, Wgt: 0.5, Ctx: 334, Dst: NodePtr{4,2}},
+		{Arr: 1, Wgt: 1, Ctx: 0, Dst: NodePtr{6,12345}},
+		{Arr: 2, Wgt: 1.25e-7, Ctx: 1, Dst: NodePtr{1,3}},
+	}
+
+	value,err := SQLLinkArray(links).(driver.Valuer).Value()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"(77,0.5,334,\"(4,2)\")","(1,1,0,\"(6,12345)\")","(2,1.25e-07,1,\"(1,3)\")"}`
+
+	if value != want {
+		t.Errorf("got %v, want %s",value,want)
+	}
+
+	// A weight too small for six decimals survives the trip
+
+	got := ParseLinkArray(want)
+
+	if !reflect.DeepEqual(got,links) {
+		t.Errorf("got %v, want %v",got,links)
+	}
+
+	empty,_ := SQLLinkArray(nil).(driver.Valuer).Value()
+
+	if empty != "{}" {
+		t.Errorf("empty link array should be {}, got %v",empty)
+	}
+}
@@ -148,7 +148,7 @@ func WaveFrontsOverlap(sst SST.PoSST,left_paths,right_paths [][]SST.Link,Lnum,Rn
 		var LRsplice []SST.Link		
 
 		LRsplice = LeftJoin(LRsplice,left_paths[lp])
-		adjoint := SST.AdjointLinkPath(right_paths[rp])
+		adjoint := SST.AdjointLinkPath(sst,right_paths[rp])
 		LRsplice = RightComplementJoin(LRsplice,adjoint)
 
 		fmt.Printf("...SPLICE PATHS L%d with R%d.....\n",lp,rp)
@@ -32,7 +32,8 @@ class Node:
 
 #######################################################
     
-# We do not allow Vertex/Edge users to define arrows.
+# We do not allow Vertex/Edge users to define arrows here yet, see
+# DefineArrow() in the Go package.
 
 ARROW_DIRECTORY = []
 INVERSE_ARROWS = []
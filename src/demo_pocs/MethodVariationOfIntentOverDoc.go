@@ -24,9 +24,9 @@ func main() {
 	//input := "../../examples/example_data/Darwin.dat"
 	//input := "../../examples/example_data/orgmode.dat"
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
-	psf,_ := SST.FractionateTextFile(input)
+	psf,_ := SST.FractionateTextFile(sst,input)
 	
 	// Rank sentences
 
@@ -41,7 +41,7 @@ func main() {
 
 			for f := 0; f < len(psf[p][s]); f++ {
 
-				score += SST.RunningIntentionality(count,psf[p][s][f])
+				score += SST.RunningIntentionality(sst,count,psf[p][s][f])
 
 				text += psf[p][s][f]
 
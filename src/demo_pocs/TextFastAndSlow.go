@@ -25,11 +25,11 @@ func main() {
 	//input := "../../examples/example_data/Darwin.dat"
 	//input := "../../examples/example_data/orgmode.dat"
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
-	_,L := SST.FractionateTextFile(input)  // loads STM_NGRAM*
+	_,L := SST.FractionateTextFile(sst,input)  // loads sst.STMNgram*
 
-	f,s,ff,ss := ExtractIntentionalTokens(L)
+	f,s,ff,ss := ExtractIntentionalTokens(sst,L)
 
 	fmt.Println("intentional fast by partition",f)
 	fmt.Println("ambient slow by partition",s)
@@ -39,9 +39,9 @@ func main() {
 
 //**************************************************************
 
-func ExtractIntentionalTokens(L int) ([][]string,[][]string,[]string,[]string) {
+func ExtractIntentionalTokens(sst SST.PoSST,L int) ([][]string,[][]string,[]string,[]string) {
 
-	slow,fast,doc_parts := SST.AssessTextFastSlow(L,SST.STM_NGRAM_LOCA)
+	slow,fast,doc_parts := SST.AssessTextFastSlow(L,sst.STMNgramLoca)
 
 	var grad_amb [SST.N_GRAM_MAX]map[string]float64
 	var grad_oth [SST.N_GRAM_MAX]map[string]float64
@@ -76,25 +76,25 @@ func ExtractIntentionalTokens(L int) ([][]string,[][]string,[]string,[]string) {
 			// Sort by intentionality
 
 			sort.Slice(amb, func(i, j int) bool {
-				ambi :=	SST.StaticIntentionality(L,amb[i],SST.STM_NGRAM_FREQ[n][amb[i]])
-				ambj := SST.StaticIntentionality(L,amb[j],SST.STM_NGRAM_FREQ[n][amb[j]])
+				ambi :=	SST.StaticIntentionality(L,amb[i],sst.STMNgramFreq[n][amb[i]])
+				ambj := SST.StaticIntentionality(L,amb[j],sst.STMNgramFreq[n][amb[j]])
 				return ambi > ambj
 			})
 
 			sort.Slice(other, func(i, j int) bool {
-				inti := SST.StaticIntentionality(L,other[i],SST.STM_NGRAM_FREQ[n][other[i]])
-				intj := SST.StaticIntentionality(L,other[j],SST.STM_NGRAM_FREQ[n][other[j]])
+				inti := SST.StaticIntentionality(L,other[i],sst.STMNgramFreq[n][other[i]])
+				intj := SST.StaticIntentionality(L,other[j],sst.STMNgramFreq[n][other[j]])
 				return inti > intj
 			})
 			
 			for i := 0 ; i < 150 && i < len(amb); i++ {
-				v := SST.StaticIntentionality(L,amb[i],SST.STM_NGRAM_FREQ[n][amb[i]])
+				v := SST.StaticIntentionality(L,amb[i],sst.STMNgramFreq[n][amb[i]])
 				slowparts[p] = append(slowparts[p],amb[i])
 				grad_amb[n][amb[i]] += v
 			}
 			
 			for i := 0 ; i < 150 && i < len(other); i++ {
-				v := SST.StaticIntentionality(L,other[i],SST.STM_NGRAM_FREQ[n][other[i]])
+				v := SST.StaticIntentionality(L,other[i],sst.STMNgramFreq[n][other[i]])
 				fastparts[p] = append(fastparts[p],other[i])
 				grad_oth[n][other[i]] += v
 			}
@@ -125,13 +125,13 @@ func ExtractIntentionalTokens(L int) ([][]string,[][]string,[]string,[]string) {
 		// Sort by intentionality
 		
 		sort.Slice(amb, func(i, j int) bool {
-			ambi := SST.StaticIntentionality(L,amb[i],SST.STM_NGRAM_FREQ[n][amb[i]])
-			ambj := SST.StaticIntentionality(L,amb[j],SST.STM_NGRAM_FREQ[n][amb[j]])
+			ambi := SST.StaticIntentionality(L,amb[i],sst.STMNgramFreq[n][amb[i]])
+			ambj := SST.StaticIntentionality(L,amb[j],sst.STMNgramFreq[n][amb[j]])
 			return ambi > ambj
 		})
 		sort.Slice(other, func(i, j int) bool {
-			inti := SST.StaticIntentionality(L,other[i],SST.STM_NGRAM_FREQ[n][other[i]])
-			intj := SST.StaticIntentionality(L,other[j],SST.STM_NGRAM_FREQ[n][other[j]])
+			inti := SST.StaticIntentionality(L,other[i],sst.STMNgramFreq[n][other[i]])
+			intj := SST.StaticIntentionality(L,other[j],sst.STMNgramFreq[n][other[j]])
 			return inti > intj
 		})
 		
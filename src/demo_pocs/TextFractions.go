@@ -26,11 +26,11 @@ func main() {
 	//input := "../../examples/example_data/Darwin.dat"
 	//input := "../../examples/example_data/orgmode.dat"
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
-	_,L := SST.FractionateTextFile(input)  // loads STM_NGRAM*
+	_,L := SST.FractionateTextFile(sst,input)  // loads sst.STMNgram*
 
-	ambient,condensed,_ := SST.AssessTextCoherentCoactivation(L,SST.STM_NGRAM_LOCA)
+	ambient,condensed,_ := SST.AssessTextCoherentCoactivation(L,sst.STMNgramLoca)
 
 	for n := 1; n < SST.N_GRAM_MAX; n++ {
 
@@ -53,18 +53,18 @@ func main() {
 		// Sort by intentionality
 
 		sort.Slice(amb, func(i, j int) bool {
-			return SST.StaticIntentionality(L,amb[i],SST.STM_NGRAM_FREQ[n][amb[i]]) > SST.StaticIntentionality(L,amb[j],SST.STM_NGRAM_FREQ[n][amb[j]])
+			return SST.StaticIntentionality(L,amb[i],sst.STMNgramFreq[n][amb[i]]) > SST.StaticIntentionality(L,amb[j],sst.STMNgramFreq[n][amb[j]])
 		})
 		sort.Slice(cond, func(i, j int) bool {
-			return SST.StaticIntentionality(L,cond[i],SST.STM_NGRAM_FREQ[n][cond[i]]) > SST.StaticIntentionality(L,cond[j],SST.STM_NGRAM_FREQ[n][cond[j]])
+			return SST.StaticIntentionality(L,cond[i],sst.STMNgramFreq[n][cond[i]]) > SST.StaticIntentionality(L,cond[j],sst.STMNgramFreq[n][cond[j]])
 		})
 
 		for i := 0 ; i < 150 && i < len(amb); i++ {
-			fmt.Println(n,"ambient",amb[i],"       ",SST.StaticIntentionality(L,amb[i],SST.STM_NGRAM_FREQ[n][amb[i]]))
+			fmt.Println(n,"ambient",amb[i],"       ",SST.StaticIntentionality(L,amb[i],sst.STMNgramFreq[n][amb[i]]))
 		}
 
 		for i := 0 ; i < 150 && i < len(cond); i++ {
-			fmt.Println(n,"condensate",cond[i],"       ",SST.StaticIntentionality(L,cond[i],SST.STM_NGRAM_FREQ[n][cond[i]]))
+			fmt.Println(n,"condensate",cond[i],"       ",SST.StaticIntentionality(L,cond[i],sst.STMNgramFreq[n][cond[i]]))
 		}
 	}
 	
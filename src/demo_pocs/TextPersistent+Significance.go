@@ -26,12 +26,12 @@ func main() {
 	//input := "../../examples/example_data/Darwin.dat"
 	//input := "../../examples/example_data/orgmode.dat"
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
-	psf,L := SST.FractionateTextFile(input)
+	psf,L := SST.FractionateTextFile(sst,input)
 
 	//intentions,context
-	intentions,_ := SST.AssessStaticTextAnomalies(L,SST.STM_NGRAM_FREQ,SST.STM_NGRAM_LOCA)
+	intentions,_ := SST.AssessStaticTextAnomalies(L,sst.STMNgramFreq,sst.STMNgramLoca)
 
 	var selections []SST.TextRank
 
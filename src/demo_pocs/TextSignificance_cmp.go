@@ -130,9 +130,9 @@ func main() {
 
 func Test1(input string,threshold float64) []int {
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
-	psf,_ := SST.FractionateTextFile(input)
+	psf,_ := SST.FractionateTextFile(sst,input)
 
 	// Rank sentences
 
@@ -149,7 +149,7 @@ func Test1(input string,threshold float64) []int {
 
 			for f := 0; f < len(psf[p][s]); f++ {
 
-				score += SST.RunningIntentionality(count,psf[p][s][f])
+				score += SST.RunningIntentionality(sst,count,psf[p][s][f])
 
 				text += psf[p][s][f]
 
@@ -207,9 +207,9 @@ func Test1(input string,threshold float64) []int {
 
 func Test2(input string,threshold float64) []int {
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
-	psf,L := SST.FractionateTextFile(input)
+	psf,L := SST.FractionateTextFile(sst,input)
 	
 	// Rank sentences
 
@@ -226,7 +226,7 @@ func Test2(input string,threshold float64) []int {
 
 			for f := 0; f < len(psf[p][s]); f++ {
 
-				score += SST.AssessStaticIntent(psf[p][s][f],L,SST.STM_NGRAM_FREQ,1)
+				score += SST.AssessStaticIntent(psf[p][s][f],L,sst.STMNgramFreq,1)
 
 				text += psf[p][s][f]
 
@@ -26,9 +26,9 @@ func main() {
 	//input := "../../examples/example_data/Darwin.dat"
 	//input := "../../examples/example_data/orgmode.dat"
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
-	psf,L := SST.FractionateTextFile(input)
+	psf,L := SST.FractionateTextFile(sst,input)
 	
 	// Rank sentences
 
@@ -45,7 +45,7 @@ func main() {
 
 			for f := 0; f < len(psf[p][s]); f++ {
 
-				score += SST.AssessStaticIntent(psf[p][s][f],L,SST.STM_NGRAM_FREQ,1)
+				score += SST.AssessStaticIntent(psf[p][s][f],L,sst.STMNgramFreq,1)
 
 				text += psf[p][s][f]
 
@@ -33,8 +33,8 @@ func main() {
 	str,ptr = SST.GetDBContextByPtr(sst,newptr2)
 	fmt.Println("confirming",ptr,"=",str)
 
-	fmt.Println("DIRECTORY CACHE",SST.CONTEXT_DIRECTORY[newptr1])
-	fmt.Println("DIRECTORY CACHE",SST.CONTEXT_DIRECTORY[newptr2])
+	fmt.Println("DIRECTORY CACHE",sst.ContextDirectory[newptr1])
+	fmt.Println("DIRECTORY CACHE",sst.ContextDirectory[newptr2])
 
 	SST.Close(sst)	
 }
@@ -0,0 +1,89 @@
+//******************************************************************
+//
+// Regenerate N4L notes from the database, e.g. to edit and
+// upload them again, or to recover lost source files
+//
+// Prepare:
+// cd examples
+// ../src/N4L -u chinese.n4l
+// ../src/exportN4L -chapter chinese
+//
+//******************************************************************
+
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+
+        SST "SSTorytime"
+)
+
+//******************************************************************
+
+var (
+	CHAPTER string
+	OUTPUT string
+)
+
+//******************************************************************
+
+func main() {
+
+	Init()
+
+	load_arrows := false
+	sst := SST.Open(load_arrows)
+
+	text,err := SST.ExportN4LErr(sst,CHAPTER)
+
+	SST.Close(sst)
+
+	if err != nil {
+		fmt.Println("exportN4L:",err)
+		os.Exit(1)
+	}
+
+	if OUTPUT == "" {
+		fmt.Print(text)
+		return
+	}
+
+	err = os.WriteFile(OUTPUT,[]byte(text),0644)
+
+	if err != nil {
+		fmt.Println("exportN4L:",err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Wrote",OUTPUT)
+}
+
+//**************************************************************
+
+func Init() {
+
+	flag.Usage = Usage
+
+	chapterPtr := flag.String("chapter","","the chapter to export, default all chapters")
+	outputPtr := flag.String("o","","write to this file instead of stdout")
+
+	flag.Parse()
+
+	if len(flag.Args()) > 0 {
+		Usage()
+	}
+
+	CHAPTER = *chapterPtr
+	OUTPUT = *outputPtr
+}
+
+//**************************************************************
+
+func Usage() {
+
+	fmt.Printf("\n\nusage: exportN4L [-chapter \"chapter name\"] [-o file.n4l]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
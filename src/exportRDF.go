@@ -0,0 +1,117 @@
+//******************************************************************
+//
+// Export chapters as RDF for triple stores: N-Triples, Turtle or
+// JSON-LD, with links as reified statements
+//
+// Prepare:
+// cd examples
+// ../src/N4L -u doors.n4l
+// ../src/exportRDF -chapter doors -o doors.ttl
+//
+//******************************************************************
+
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+
+        SST "SSTorytime"
+)
+
+//******************************************************************
+
+var (
+	CHAPTER string
+	FORMAT string
+	BASE string
+	OUTPUT string
+)
+
+//******************************************************************
+
+func main() {
+
+	Init()
+
+	load_arrows := true
+	sst := SST.Open(load_arrows)
+
+	var chapters []string
+
+	if CHAPTER == "" {
+		chapters = SST.GetDBChaptersMatchingName(sst,"")
+	} else {
+		chapters = []string{CHAPTER}
+	}
+
+	g,err := SST.ExportGraphFromChaptersErr(sst,chapters)
+
+	var text string
+
+	if err == nil {
+		text,err = SST.FormatRDF(sst,g,BASE,FORMAT)
+	}
+
+	SST.Close(sst)
+
+	if err != nil {
+		fmt.Println("exportRDF:",err)
+		os.Exit(1)
+	}
+
+	if OUTPUT == "" {
+		fmt.Print(text)
+		return
+	}
+
+	err = os.WriteFile(OUTPUT,[]byte(text),0644)
+
+	if err != nil {
+		fmt.Println("exportRDF:",err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Wrote",OUTPUT)
+}
+
+//**************************************************************
+
+func Init() {
+
+	flag.Usage = Usage
+
+	chapterPtr := flag.String("chapter","","the chapter to export, default all chapters")
+	formatPtr := flag.String("format","","nt, ttl or jsonld, default from the -o file extension, else ttl")
+	basePtr := flag.String("base",SST.RDF_BASE,"prefix for node and arrow IRIs")
+	outputPtr := flag.String("o","","write to this file instead of stdout")
+
+	flag.Parse()
+
+	if len(flag.Args()) > 0 {
+		Usage()
+	}
+
+	CHAPTER = *chapterPtr
+	BASE = *basePtr
+	OUTPUT = *outputPtr
+	FORMAT = *formatPtr
+
+	if FORMAT == "" {
+		FORMAT = SST.ExportGraphFormat(OUTPUT)
+	}
+
+	if FORMAT == "" {
+		FORMAT = "ttl"
+	}
+}
+
+//**************************************************************
+
+func Usage() {
+
+	fmt.Printf("\n\nusage: exportRDF [-chapter \"chapter name\"] [-format nt|ttl|jsonld] [-base iri] [-o file]\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
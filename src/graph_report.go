@@ -19,6 +19,8 @@ var CHAPTER string
 var CONTEXT []string
 var STTYPES []int
 var DEPTH int
+var EXPORT string
+var GRAPH_FILE string
 
 //******************************************************************
 
@@ -26,8 +28,14 @@ func main() {
 
 	Init()
 
-	load_arrows := true
-	sst := SST.Open(load_arrows)
+	var sst SST.PoSST
+
+	if GRAPH_FILE != "" {
+		sst = SST.OpenGraphFile(GRAPH_FILE)
+	} else {
+		load_arrows := true
+		sst = SST.Open(load_arrows)
+	}
 
 	chaps := SST.GetDBChaptersMatchingName(sst,CHAPTER)
 
@@ -35,6 +43,10 @@ func main() {
 		AnalyzeGraph(sst,chaps[chap],CONTEXT,STTYPES,DEPTH) 
 	}
 
+	if EXPORT != "" {
+		ExportChapters(sst,chaps)
+	}
+
 	SST.Close(sst)
 }
 
@@ -42,7 +54,7 @@ func main() {
 
 func Usage() {
 	
-	fmt.Printf("usage: graph_report [-sttype comma separated L,C,P,N] [-depth integer] [-chapter comma separated string] [context]\n")
+	fmt.Printf("usage: graph_report [-sttype comma separated L,C,P,N] [-depth integer] [-chapter comma separated string] [-export file.graphml|.gexf|.dot] [-graph notes.sstg] [context]\n")
 	flag.PrintDefaults()
 
 	os.Exit(2)
@@ -57,6 +69,8 @@ func Init() []string {
 	chapterPtr := flag.String("chapter", "", "a optional substring to match specific chapters")
 	sttypePtr := flag.String("sttype", "+L", "link st-types e.g. L,C,P,N")
 	depthPtr := flag.Int("depth", 3, "maximum probe depth for loop detection")
+	exportPtr := flag.String("export", "", "also write the chapters to a .graphml, .gexf or .dot file")
+	graphPtr := flag.String("graph", "", "read this graph file, written by N4L -o, instead of the database")
 
 	flag.Parse()
 	args := flag.Args()
@@ -103,8 +117,8 @@ func Init() []string {
 	}
 
 	DEPTH = *depthPtr
-
-	SST.MemoryInit()
+	EXPORT = *exportPtr
+	GRAPH_FILE = *graphPtr
 
 	return args
 }
@@ -390,3 +404,27 @@ func PrintMatrix(matrix [][]float32,symbolic [][]string,str string) {
 	fmt.Println()
 }
 
+
+//******************************************************************
+
+func ExportChapters(sst SST.PoSST,chaps []string) {
+
+	g,err := SST.ExportGraphFromChaptersErr(sst,chaps)
+
+	var text string
+
+	if err == nil {
+		text,err = SST.FormatExportGraph(sst,g,SST.ExportGraphFormat(EXPORT))
+	}
+
+	if err == nil {
+		err = os.WriteFile(EXPORT,[]byte(text),0644)
+	}
+
+	if err != nil {
+		fmt.Println("graph_report: export:",err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nWrote %d nodes and %d links to %s\n",len(g.Nodes),len(g.Edges),EXPORT)
+}
@@ -0,0 +1,513 @@
+//******************************************************************
+//
+// A language server for N4L notes, so editors can show mistakes
+// while you type, using the same parser as the N4L command
+//
+// Speaks the Language Server Protocol on stdin/stdout, e.g.
+//
+//  nvim:   vim.lsp.start({ name = "n4l", cmd = { "n4l-lsp" } })
+//
+// Arrow names come from SSTconfig, found as for N4L but relative
+// to the notes file, or from SST_CONFIG_PATH or -config
+//
+//******************************************************************
+
+package main
+
+import (
+	"os"
+	"io"
+	"fmt"
+	"flag"
+	"bufio"
+	"strings"
+	"net/url"
+	"path/filepath"
+	"encoding/json"
+
+        SST "SSTorytime"
+        N4L "SSTorytime/N4L"
+        LSP "SSTorytime/N4L/lsp"
+)
+
+//******************************************************************
+
+const (
+	// LSP protocol numbers
+
+	LSP_ERROR = 1
+	LSP_WARNING = 2
+
+	LSP_COMPLETION_ENUM_MEMBER = 20
+
+	LSP_METHOD_NOT_FOUND = -32601
+
+	// Don't reparse huge files on every keystroke, only on save
+
+	LIVE_PARSE_LIMIT = N4L.LARGE_FILE
+)
+
+//******************************************************************
+
+var (
+	CONFIG_DIR string
+	OUT *bufio.Writer
+
+	DOCUMENTS = make(map[string]string)      // uri -> current text
+	ARROWS = make(map[string]*N4L.Graph)      // config dir -> arrows only
+	SHUTDOWN bool
+)
+
+//******************************************************************
+// Protocol types, only the parts we use
+//******************************************************************
+
+type RPCRequest struct {
+
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type RPCResponse struct {
+
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type RPCNotification struct {
+
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type RPCError struct {
+
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type Location struct {
+
+	URI   string       `json:"uri"`
+	Range LSP.Range `json:"range"`
+}
+
+type Diagnostic struct {
+
+	Range    LSP.Range `json:"range"`
+	Severity int          `json:"severity"`
+	Code     string       `json:"code"`
+	Source   string       `json:"source"`
+	Message  string       `json:"message"`
+}
+
+type CompletionItem struct {
+
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+type MarkupContent struct {
+
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Hover struct {
+
+	Contents MarkupContent `json:"contents"`
+	Range    LSP.Range  `json:"range"`
+}
+
+type TextDocumentParams struct {
+
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+
+	Position LSP.Position `json:"position"`
+}
+
+//******************************************************************
+// BEGIN
+//******************************************************************
+
+func main() {
+
+	Init()
+
+	// Anything the libraries print must not get mixed up with the protocol
+
+	OUT = bufio.NewWriter(os.Stdout)
+	os.Stdout = os.Stderr
+
+	in := bufio.NewReader(os.Stdin)
+
+	for {
+		msg,err := LSP.ReadMessage(in)
+
+		if err == io.EOF {
+			os.Exit(1)
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr,"n4l-lsp:",err)
+			os.Exit(1)
+		}
+
+		var req RPCRequest
+
+		if json.Unmarshal(msg,&req) != nil {
+			continue
+		}
+
+		HandleRequest(req)
+	}
+}
+
+//******************************************************************
+
+func Init() {
+
+	flag.Usage = Usage
+
+	configPtr := flag.String("config","","directory containing the arrow configuration, default nearest SSTconfig")
+
+	flag.Parse()
+
+	if len(flag.Args()) > 0 {
+		Usage()
+	}
+
+	CONFIG_DIR = *configPtr
+}
+
+//******************************************************************
+
+func Usage() {
+
+	fmt.Fprintf(os.Stderr,"usage: n4l-lsp [-config dir]\n\nA language server for N4L, run by an editor over stdin/stdout\n\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+//******************************************************************
+
+func HandleRequest(req RPCRequest) {
+
+	var params TextDocumentParams
+
+	json.Unmarshal(req.Params,&params)
+
+	uri := params.TextDocument.URI
+
+	switch req.Method {
+
+	case "initialize":
+		Reply(req,InitializeResult())
+
+	case "shutdown":
+		SHUTDOWN = true
+		Reply(req,nil)
+
+	case "exit":
+		if SHUTDOWN {
+			os.Exit(0)
+		}
+		os.Exit(1)
+
+	case "textDocument/didOpen":
+		DOCUMENTS[uri] = params.TextDocument.Text
+		PublishDiagnostics(uri)
+
+	case "textDocument/didChange":
+		if len(params.ContentChanges) > 0 {
+			DOCUMENTS[uri] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		}
+		if len(DOCUMENTS[uri]) < LIVE_PARSE_LIMIT {
+			PublishDiagnostics(uri)
+		}
+
+	case "textDocument/didSave":
+		PublishDiagnostics(uri)
+
+	case "textDocument/didClose":
+		delete(DOCUMENTS,uri)
+		Notify("textDocument/publishDiagnostics",map[string]interface{}{"uri": uri,"diagnostics": []Diagnostic{}})
+
+	case "textDocument/completion":
+		Reply(req,Completion(uri,params.Position))
+
+	case "textDocument/hover":
+		Reply(req,HoverArrow(uri,params.Position))
+
+	case "textDocument/definition":
+		Reply(req,Definition(uri,params.Position))
+
+	case "textDocument/documentSymbol":
+		Reply(req,Outline(uri))
+
+	default:
+		// Requests need an answer, notifications we don't know can be ignored
+
+		if len(req.ID) > 0 {
+			var e RPCError
+			e.Code = LSP_METHOD_NOT_FOUND
+			e.Message = "method not supported: "+req.Method
+			Send(RPCResponse{JSONRPC: "2.0", ID: req.ID, Error: &e})
+		}
+	}
+}
+
+//******************************************************************
+
+func InitializeResult() map[string]interface{} {
+
+	capabilities := map[string]interface{}{
+		"textDocumentSync": map[string]interface{}{
+			"openClose": true,
+			"change": 1, // full text
+			"save": true,
+		},
+		"completionProvider": map[string]interface{}{
+			"triggerCharacters": []string{"("},
+		},
+		"hoverProvider": true,
+		"definitionProvider": true,
+		"documentSymbolProvider": true,
+	}
+
+	return map[string]interface{}{
+		"capabilities": capabilities,
+		"serverInfo": map[string]string{"name": "n4l-lsp"},
+	}
+}
+
+//******************************************************************
+// Diagnostics
+//******************************************************************
+
+func PublishDiagnostics(uri string) {
+
+	text := DOCUMENTS[uri]
+	path := URIToPath(uri)
+	lines := strings.Split(text,"\n")
+
+	cfg := N4L.Config{ConfigFiles: ConfigFor(path)}
+
+	g,diags := N4L.NewGraph(SST.OpenMemory(),cfg)
+	diags = append(diags,N4L.ParseInto(g,strings.NewReader(text),path,cfg)...)
+
+	var list []Diagnostic = []Diagnostic{}
+
+	for _,d := range diags {
+
+		var ld Diagnostic
+
+		ld.Severity = LSP_ERROR
+
+		if d.Severity == N4L.SEVERITY_WARNING {
+			ld.Severity = LSP_WARNING
+		}
+
+		ld.Code = d.Code
+		ld.Source = "N4L"
+		ld.Message = d.Message
+
+		if d.File != path {
+			// In the configuration or an included file, not this one
+			ld.Message = d.File+": "+d.Message
+		} else {
+			ld.Range = LSP.TokenRange(lines,d.Line-1,d.Column-1)
+		}
+
+		list = append(list,ld)
+	}
+
+	Notify("textDocument/publishDiagnostics",map[string]interface{}{"uri": uri,"diagnostics": list})
+}
+
+//******************************************************************
+// Arrows
+//******************************************************************
+
+func ConfigFor(path string) []string {
+
+	if CONFIG_DIR != "" {
+		return append(N4L.ConfigFilesIn(CONFIG_DIR),N4L.LocalConfigFilesFrom(filepath.Dir(path))...)
+	}
+
+	return N4L.FindConfigFilesFrom(filepath.Dir(path))
+}
+
+//******************************************************************
+
+func GetArrows(uri string) SST.PoSST {
+
+	// The arrows defined for this file, read once per configuration
+
+	config := ConfigFor(URIToPath(uri))
+	key := strings.Join(config,",")
+
+	g,cached := ARROWS[key]
+
+	if !cached {
+		g,_ = N4L.NewGraph(SST.OpenMemory(),N4L.Config{ConfigFiles: config})
+		ARROWS[key] = g
+	}
+
+	return g.PoSST
+}
+
+//******************************************************************
+
+func Completion(uri string,pos LSP.Position) []CompletionItem {
+
+	// Arrow names, when inside ( ... )
+
+	var items []CompletionItem = []CompletionItem{}
+
+	line,col := LSP.LineAt(DOCUMENTS[uri],pos)
+
+	if _,_,inside := LSP.ArrowAt(line,col,false); !inside {
+		return items
+	}
+
+	sst := GetArrows(uri)
+
+	for _,arr := range sst.ArrowDirectory {
+
+		if arr.Ptr < 2 {
+			continue // empty/void are only for internal use
+		}
+
+		sttype := SST.STTypeName(SST.STIndexToSTType(arr.STAindex))
+
+		items = append(items,CompletionItem{arr.Short,LSP_COMPLETION_ENUM_MEMBER,arr.Long+"  "+sttype})
+
+		if arr.Long != arr.Short {
+			items = append(items,CompletionItem{arr.Long,LSP_COMPLETION_ENUM_MEMBER,"("+arr.Short+")  "+sttype})
+		}
+	}
+
+	return items
+}
+
+//******************************************************************
+
+func HoverArrow(uri string,pos LSP.Position) interface{} {
+
+	// The meaning of the arrow under the cursor
+
+	line,col := LSP.LineAt(DOCUMENTS[uri],pos)
+
+	from,to,inside := LSP.ArrowAt(line,col,true)
+
+	if !inside {
+		return nil
+	}
+
+	name := strings.TrimSpace(strings.Split(string(line[from:to]),",")[0])
+
+	text,ok := LSP.ArrowHover(GetArrows(uri),name)
+
+	if !ok {
+		return nil
+	}
+
+	var h Hover
+
+	h.Contents.Kind = "markdown"
+	h.Contents.Value = text
+
+	h.Range.Start = LSP.Position{Line: pos.Line,Character: LSP.RuneToUTF16(line,from)}
+	h.Range.End = LSP.Position{Line: pos.Line,Character: LSP.RuneToUTF16(line,to)}
+
+	return h
+}
+
+//******************************************************************
+// Aliases
+//******************************************************************
+
+func Definition(uri string,pos LSP.Position) interface{} {
+
+	// From $alias.n or @alias to the line labelled @alias
+
+	r,ok := LSP.AliasDefinition(DOCUMENTS[uri],pos)
+
+	if !ok {
+		return nil
+	}
+
+	return Location{uri,r}
+}
+
+//******************************************************************
+// Outline
+//******************************************************************
+
+func Outline(uri string) []LSP.DocumentSymbol {
+
+	// -chapters, with the :: context :: blocks inside them
+
+	return LSP.Outline(DOCUMENTS[uri])
+}
+
+//******************************************************************
+// Tools
+//******************************************************************
+
+func URIToPath(uri string) string {
+
+	u,err := url.Parse(uri)
+
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+
+	return u.Path
+}
+
+//******************************************************************
+// JSON-RPC over stdio
+//******************************************************************
+
+func Reply(req RPCRequest,result interface{}) {
+
+	Send(RPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+//******************************************************************
+
+func Notify(method string,params interface{}) {
+
+	Send(RPCNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+//******************************************************************
+
+func Send(msg interface{}) {
+
+	body,err := json.Marshal(msg)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr,"n4l-lsp:",err)
+		return
+	}
+
+	fmt.Fprintf(OUT,"Content-Length: %d\r\n\r\n",len(body))
+	OUT.Write(body)
+	OUT.Flush()
+}
@@ -0,0 +1,225 @@
+//******************************************************************
+//
+// n4lfmt - rewrite N4L notes in a canonical layout, like gofmt
+//
+// One space between items, one blank line around chapters and
+// contexts, ditto marks lined up under the item they repeat, and
+// optionally every arrow in its short or long name. Comments,
+// aliases and the order of lines are kept
+//
+// A file is only rewritten if it parses cleanly and the result
+// gives exactly the same graph
+//
+//******************************************************************
+
+package main
+
+import (
+	"os"
+	"io"
+	"fmt"
+	"flag"
+	"path/filepath"
+
+        N4L "SSTorytime/N4L"
+)
+
+//******************************************************************
+
+var (
+	WRITE bool
+	CHECK bool
+	ARROWS string
+	CONFIG_DIR string
+)
+
+//******************************************************************
+// BEGIN
+//******************************************************************
+
+func main() {
+
+	args := Init()
+
+	if len(args) == 0 {
+
+		// A filter, for editors
+
+		src,err := io.ReadAll(os.Stdin)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr,"n4lfmt:",err)
+			os.Exit(2)
+		}
+
+		text,ok := FormatFile([]rune(string(src)),"<stdin>")
+
+		if !ok {
+			os.Exit(2)
+		}
+
+		if CHECK {
+			if text != string(src) {
+				fmt.Println("<stdin>")
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		fmt.Print(text)
+		os.Exit(0)
+	}
+
+	failed := false
+	changed := false
+
+	for _,file := range args {
+
+		src,err := N4L.ReadFile(file)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr,"n4lfmt:",err)
+			failed = true
+			continue
+		}
+
+		text,ok := FormatFile(src,file)
+
+		if !ok {
+			failed = true
+			continue
+		}
+
+		differs := text != string(src)
+
+		switch {
+
+		case CHECK:
+			if differs {
+				fmt.Println(file)
+				changed = true
+			}
+
+		case WRITE:
+			if differs {
+				if err := WriteFile(file,text); err != nil {
+					fmt.Fprintln(os.Stderr,"n4lfmt:",err)
+					failed = true
+				}
+			}
+
+		default:
+			fmt.Print(text)
+		}
+	}
+
+	if failed {
+		os.Exit(2)
+	}
+
+	if changed {
+		os.Exit(1)
+	}
+}
+
+//******************************************************************
+
+func Init() []string {
+
+	flag.Usage = Usage
+
+	writePtr := flag.Bool("w",false,"write the result back to the file instead of stdout")
+	checkPtr := flag.Bool("check",false,"only list files that are not formatted, and exit 1 if there are any")
+	arrowsPtr := flag.String("arrows","","rewrite arrows to their \"short\" or \"long\" names")
+	configPtr := flag.String("config","","directory containing the arrow configuration, default nearest SSTconfig")
+
+	flag.Parse()
+
+	WRITE = *writePtr
+	CHECK = *checkPtr
+	ARROWS = *arrowsPtr
+	CONFIG_DIR = *configPtr
+
+	if WRITE && CHECK {
+		fmt.Fprintln(os.Stderr,"Use either -w or -check, not both")
+		os.Exit(2)
+	}
+
+	switch ARROWS {
+	case N4L.FORMAT_ARROWS_AS_IS,N4L.FORMAT_ARROWS_SHORT,N4L.FORMAT_ARROWS_LONG:
+	default:
+		fmt.Fprintln(os.Stderr,"-arrows should be short or long, not",ARROWS)
+		os.Exit(2)
+	}
+
+	if WRITE && len(flag.Args()) == 0 {
+		fmt.Fprintln(os.Stderr,"-w needs files to write to")
+		os.Exit(2)
+	}
+
+	return flag.Args()
+}
+
+//******************************************************************
+
+func Usage() {
+
+	fmt.Fprintf(os.Stderr,"usage: n4lfmt [-w | -check] [-arrows short|long] [-config dir] [file.n4l ...]\n\nWith no files, formats stdin to stdout\n\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+//******************************************************************
+
+func FormatFile(src []rune,file string) (string,bool) {
+
+	// Arrow names are looked up with the configuration nearest the file
+
+	var cfg N4L.Config
+
+	if CONFIG_DIR != "" {
+		cfg.ConfigFiles = append(N4L.ConfigFilesIn(CONFIG_DIR),N4L.LocalConfigFilesFrom(filepath.Dir(file))...)
+	} else {
+		cfg.ConfigFiles = N4L.FindConfigFilesFrom(filepath.Dir(file))
+	}
+
+	text,diags,err := N4L.FormatChecked(src,file,ARROWS,cfg)
+
+	for _,d := range diags {
+		if d.Severity == N4L.SEVERITY_ERROR {
+			fmt.Fprintln(os.Stderr,N4L.FormatDiagnostic(d))
+		}
+	}
+
+	if N4L.HasErrors(diags) {
+		return "",false
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr,"%s: %v\n",file,err)
+		return "",false
+	}
+
+	return text,true
+}
+
+//******************************************************************
+
+func WriteFile(file,text string) error {
+
+	// Keep the permissions, and don't leave half a file behind
+
+	info,err := os.Stat(file)
+
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".n4lfmt"
+
+	if err := os.WriteFile(tmp,[]byte(text),info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp,file)
+}
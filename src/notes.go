@@ -17,6 +17,7 @@ import (
 )
 
 var PAGENR int = 1
+var GRAPH_FILE string
 
 //******************************************************************
 
@@ -24,8 +25,14 @@ func main() {
 
 	args := Init()
 
-	load_arrows := true
-	sst := SST.Open(load_arrows)
+	var sst SST.PoSST
+
+	if GRAPH_FILE != "" {
+		sst = SST.OpenGraphFile(GRAPH_FILE)
+	} else {
+		load_arrows := true
+		sst = SST.Open(load_arrows)
+	}
 
 	chapter := ""
 
@@ -48,7 +55,7 @@ func main() {
 
 func Usage() {
 	
-	fmt.Printf("usage: Notes [chapter or section]\n")
+	fmt.Printf("usage: Notes [-page n] [-graph notes.sstg] [chapter or section]\n")
 	flag.PrintDefaults()
 
 	os.Exit(2)
@@ -59,6 +66,7 @@ func Usage() {
 func Init() []string {
 
 	pagePtr := flag.Int("page", 1, "page number for browsing")
+	graphPtr := flag.String("graph", "", "read this graph file, written by N4L -o, instead of the database")
 
 	flag.Usage = Usage
 
@@ -66,14 +74,13 @@ func Init() []string {
 	args := flag.Args()
 
 	PAGENR = *pagePtr
+	GRAPH_FILE = *graphPtr
 
 	if len(args) == 0 {
 		fmt.Println("\nEnter a chapter to browse")
 		os.Exit(-1)
 	}
 
-	SST.MemoryInit()
-
 	return args
 }
 
@@ -88,7 +95,7 @@ func Page(sst SST.PoSST,chapter string,context []string,page int) {
 
 	for n := 0; n < len(notes); n++ {
 
-		txtctx := SST.CONTEXT_DIRECTORY[notes[n].Context].Context
+		txtctx := sst.ContextDirectory[notes[n].Context].Context
 	
 		if last != notes[n].Chapter || lastc != txtctx {
 			fmt.Println("\n---------------------------------------------")
@@ -25,6 +25,7 @@ var (
 	VERBOSE bool
 	FWD     string
 	BWD     string
+	GRAPH_FILE string
 )
 
 //******************************************************************
@@ -33,8 +34,14 @@ func main() {
 
 	Init()
 
-	load_arrows := true
-	sst := SST.Open(load_arrows)
+	var sst SST.PoSST
+
+	if GRAPH_FILE != "" {
+		sst = SST.OpenGraphFile(GRAPH_FILE)
+	} else {
+		load_arrows := true
+		sst = SST.Open(load_arrows)
+	}
 
 	PathSolve(sst,CHAPTER,CONTEXT,BEGIN,END)
 
@@ -44,7 +51,7 @@ func main() {
 
 func Usage() {
 	
-	fmt.Printf("usage: PathSolve [-v] -begin <string> -end <string> [-chapter string] subject [context]\n")
+	fmt.Printf("usage: PathSolve [-v] [-graph notes.sstg] -begin <string> -end <string> [-chapter string] subject [context]\n")
 	flag.PrintDefaults()
 
 	os.Exit(2)
@@ -61,6 +68,7 @@ func Init() []string {
 	beginPtr := flag.String("begin", "", "a string match start/begin set")
 	endPtr := flag.String("end", "", "a string to match final end set")
 	dirPtr := flag.Bool("bwd", false, "reverse search direction")
+	graphPtr := flag.String("graph", "", "read this graph file, written by N4L -o, instead of the database")
 
 	flag.Parse()
 	args := flag.Args()
@@ -70,6 +78,7 @@ func Init() []string {
 	}
 
 	CHAPTER = ""
+	GRAPH_FILE = *graphPtr
 
 	if *dirPtr {
 		FWD = "bwd"
@@ -109,8 +118,6 @@ func Init() []string {
 		}
 	} 
 
-	SST.MemoryInit()
-
 	return args
 }
 
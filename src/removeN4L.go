@@ -65,8 +65,6 @@ func Init() []string {
 		os.Exit(1);
 	}
 
-	SST.MemoryInit()
-
 	return args
 }
 
@@ -83,16 +81,16 @@ func Usage() {
 
 func DeleteChapter(sst SST.PoSST,chapter string) {
 
-	qstr := fmt.Sprintf("select DeleteChapter('%s')",chapter)
+	qstr := "select DeleteChapter($1)"
 
-	row,err := sst.DB.Query(qstr)
+	row,err := sst.DB.Query(qstr,chapter)
 	
 	if err != nil {
 		fmt.Println("Error running deletechapter function:",qstr,err)
-	} else {
-		fmt.Println("Deleted",chapter)
+		return
 	}
 
+	fmt.Println("Deleted",chapter)
 	row.Close()
 
 }
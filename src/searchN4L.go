@@ -14,12 +14,19 @@ import (
 	"strings"
 
         SST "SSTorytime"
+        N4L "SSTorytime/N4L"
 )
 
 //******************************************************************
 
 var VERBOSE bool = false
 
+var EXPORT string          // write the results to this graph file too
+var NOTES string           // search these N4L files instead of the database
+var GRAPH_FILE string      // or this graph file from N4L -o
+var EXPORT_NODES []SST.NodePtr
+var EXPORT_PATHS [][]SST.Link
+
 var TESTS = []string{ 
 	"range rover out of its depth",
 	"\"range rover\" \"out of its depth\"",
@@ -68,10 +75,16 @@ func main() {
 
 	args := GetArgs()
 
-	SST.MemoryInit()
+	var sst SST.PoSST
 
-	load_arrows := false
-	sst := SST.Open(load_arrows)
+	if NOTES != "" {
+		sst = OpenNotes(strings.Split(NOTES,","))
+	} else if GRAPH_FILE != "" {
+		sst = SST.OpenGraphFile(GRAPH_FILE)
+	} else {
+		load_arrows := false
+		sst = SST.Open(load_arrows)
+	}
 
 	var search SST.SearchParameters
 
@@ -93,6 +106,11 @@ func main() {
 	search = SST.DecodeSearchField(search_string)
 	
 	Search(sst,search,search_string)
+
+	if EXPORT != "" {
+		ExportResults(sst)
+	}
+
 	SST.Close(sst)
 	return
 }
@@ -132,6 +150,8 @@ func Usage() {
 	fmt.Println("searchN4L a1 to b6 arrows then")
 	fmt.Println("searchN4L paths a2 to b5 distance 10")
 	fmt.Println("searchN4L <b5|a2> distance 10")
+	fmt.Println("searchN4L -export map.gexf from start")
+	fmt.Println("searchN4L -graph notes.sstg from start")
 
 	flag.PrintDefaults()
 
@@ -144,17 +164,57 @@ func GetArgs() []string {
 
 	flag.Usage = Usage
 	verbosePtr := flag.Bool("v", false,"verbose")
+	exportPtr := flag.String("export","","also write the results to a .graphml, .gexf or .dot file")
+	notesPtr := flag.String("notes","","search these comma separated N4L files in memory, without the database")
+	graphPtr := flag.String("graph","","search this graph file, written by N4L -o, without the database")
 	flag.Parse()
 
 	if *verbosePtr {
 		VERBOSE = true
 	}
 
+	EXPORT = *exportPtr
+	NOTES = *notesPtr
+	GRAPH_FILE = *graphPtr
+
+	if NOTES != "" && GRAPH_FILE != "" {
+		fmt.Println("Use either -notes or -graph, not both")
+		os.Exit(2)
+	}
+
 	return flag.Args()
 }
 
 //******************************************************************
 
+func OpenNotes(files []string) SST.PoSST {
+
+	// Parse the notes into memory, where the queries can be
+	// answered without a database
+
+	var cfg N4L.Config
+
+	graph,diags := N4L.NewGraph(SST.OpenMemory(),cfg)
+
+	for _,file := range files {
+		diags = append(diags,N4L.ParseFile(graph,file,cfg)...)
+	}
+
+	for _,d := range diags {
+		if d.Severity == N4L.SEVERITY_ERROR {
+			fmt.Fprintln(os.Stderr,N4L.FormatDiagnostic(d))
+		}
+	}
+
+	if N4L.HasErrors(diags) {
+		os.Exit(-1)
+	}
+
+	return graph.PoSST
+}
+
+//******************************************************************
+
 func Search(sst SST.PoSST, search SST.SearchParameters,line string) {
 
 	if VERBOSE {
@@ -360,6 +420,7 @@ func FindOrbits(sst SST.PoSST, nptrs []SST.NodePtr, limit int) {
 		}
 		fmt.Print("\n",nptr,": ")
 		SST.PrintNodeOrbit(sst,nptrs[nptr],limit)
+		EXPORT_NODES = append(EXPORT_NODES,nptrs[nptr])
 	}
 }
 
@@ -450,6 +511,7 @@ func PathSolve(sst SST.PoSST,leftptrs,rightptrs []SST.NodePtr,chapter string,con
 				prefix := fmt.Sprintf(" - story path: ")
 				PrintConstrainedLinkPath(sst,solutions,s,prefix,chapter,context,arrowptrs,sttype)
 			}
+			EXPORT_PATHS = append(EXPORT_PATHS,solutions...)
 			count++
 			break
 		}
@@ -472,14 +534,14 @@ func ShowMatchingArrows(sst SST.PoSST,arrowptrs []SST.ArrowPtr,sttype []int) {
 
 	for a := range arrowptrs {
 		adir := SST.GetDBArrowByPtr(sst,arrowptrs[a])
-		inv := SST.GetDBArrowByPtr(sst,SST.INVERSE_ARROWS[arrowptrs[a]])
+		inv := SST.GetDBArrowByPtr(sst,SST.GetInverseArrow(sst,arrowptrs[a]))
 		fmt.Printf("%3d. (st %d) %s -> %s,  with inverse = %3d. (st %d) %s -> %s\n",arrowptrs[a],SST.STIndexToSTType(adir.STAindex),adir.Short,adir.Long,inv.Ptr,SST.STIndexToSTType(inv.STAindex),inv.Short,inv.Long)
 	}
 
 	for st := range sttype {
 		adirs := SST.GetDBArrowBySTType(sst,sttype[st])
 		for adir := range adirs {
-			inv := SST.GetDBArrowByPtr(sst,SST.INVERSE_ARROWS[adirs[adir].Ptr])
+			inv := SST.GetDBArrowByPtr(sst,SST.GetInverseArrow(sst,adirs[adir].Ptr))
 			fmt.Printf("%3d. (st %d) %s -> %s,  with inverse = %3d. (st %d) %s -> %s\n",adirs[adir].Ptr,SST.STIndexToSTType(adirs[adir].STAindex),adirs[adir].Short,adirs[adir].Long,inv.Ptr,SST.STIndexToSTType(inv.STAindex),inv.Short,inv.Long)
 		}
 	}
@@ -641,6 +703,7 @@ func ShowCone(sst SST.PoSST,cone [][]SST.Link,chap string,context []string,limit
 
 	for s := 0; s < len(cone) && s < limit; s++ {
 		SST.PrintSomeLinkPath(sst,cone,s," - ",chap,context,limit)
+		EXPORT_PATHS = append(EXPORT_PATHS,cone[s])
 		count++
 	}
 
@@ -724,7 +787,7 @@ func ShowNotes(sst SST.PoSST,notes []SST.PageMap) {
 
 	for n := 0; n < len(notes); n++ {
 
-		txtctx := SST.CONTEXT_DIRECTORY[notes[n].Context].Context
+		txtctx := sst.ContextDirectory[notes[n].Context].Context
 		
 		if last != notes[n].Chapter || lastc != txtctx {
 
@@ -763,3 +826,37 @@ func ShowTime(sst SST.PoSST,search SST.SearchParameters) {
 }
 
 
+
+// **********************************************************
+
+func ExportResults(sst SST.PoSST) {
+
+	// Paths and cones carry their own links, plain matches get
+	// the links between them
+
+	var g SST.ExportGraph
+	var err error
+
+	if EXPORT_PATHS != nil {
+		g,err = SST.ExportGraphFromLinkPathsErr(sst,EXPORT_PATHS)
+	} else {
+		g,err = SST.ExportGraphFromNodesErr(sst,EXPORT_NODES)
+	}
+
+	var text string
+
+	if err == nil {
+		text,err = SST.FormatExportGraph(sst,g,SST.ExportGraphFormat(EXPORT))
+	}
+
+	if err == nil {
+		err = os.WriteFile(EXPORT,[]byte(text),0644)
+	}
+
+	if err != nil {
+		fmt.Println("searchN4L: export:",err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Wrote %d nodes and %d links to %s\n",len(g.Nodes),len(g.Edges),EXPORT)
+}
@@ -332,8 +332,10 @@ func HandleSearch(search SST.SearchParameters, line string, w http.ResponseWrite
 	}
 
 	if (name && from) || (name && to) {
-		fmt.Printf("\nSearch \"%s\" has conflicting parts <to|from> and match strings\n", line)
-		os.Exit(-1)
+		msg := fmt.Sprintf("Search \"%s\" has conflicting parts <to|from> and match strings", line)
+		fmt.Println(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
 	}
 
 	// Closed path solving, two sets of nodeptrs
@@ -508,6 +510,21 @@ func PackageConeFromOrigin(ctx SST.PoSST, nptr SST.NodePtr, nth int, sttype int,
 
 //******************************************************************
 
+func SuperConePair(ctx SST.PoSST, lorient, rorient string, leftptrs, rightptrs []SST.NodePtr, ldepth, rdepth int, chapter string, context []string, maxdepth int) ([][]SST.Link, [][]SST.Link, int, int, error) {
+
+	left_paths, Lnum, err := SST.GetEntireNCSuperConePathsAsLinksErr(ctx, lorient, leftptrs, ldepth, chapter, context, maxdepth)
+
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+
+	right_paths, Rnum, err := SST.GetEntireNCSuperConePathsAsLinksErr(ctx, rorient, rightptrs, rdepth, chapter, context, maxdepth)
+
+	return left_paths, right_paths, Lnum, Rnum, err
+}
+
+//******************************************************************
+
 func HandlePathSolve(w http.ResponseWriter, r *http.Request, ctx SST.PoSST, leftptrs, rightptrs []SST.NodePtr, search SST.SearchParameters, arrowptrs []SST.ArrowPtr, sttype []int, maxdepth int) {
 
 	chapter := search.Chapter
@@ -517,6 +534,7 @@ func HandlePathSolve(w http.ResponseWriter, r *http.Request, ctx SST.PoSST, left
 
 	var Lnum, Rnum int
 	var left_paths, right_paths [][]SST.Link
+	var err error
 
 	// Find the path matrix
 
@@ -525,13 +543,21 @@ func HandlePathSolve(w http.ResponseWriter, r *http.Request, ctx SST.PoSST, left
 
 	for turn := 0; ldepth < maxdepth && rdepth < maxdepth; turn++ {
 
-		left_paths, Lnum = SST.GetEntireNCSuperConePathsAsLinks(CTX, "fwd", leftptrs, ldepth, chapter, context, maxdepth)
-		right_paths, Rnum = SST.GetEntireNCSuperConePathsAsLinks(CTX, "bwd", rightptrs, rdepth, chapter, context, maxdepth)
+		left_paths, right_paths, Lnum, Rnum, err = SuperConePair(CTX, "fwd", "bwd", leftptrs, rightptrs, ldepth, rdepth, chapter, context, maxdepth)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		if Lnum == 0 || Rnum == 0 {
 			fmt.Println("Nothing, trying reverse")
-			left_paths, Lnum = SST.GetEntireNCSuperConePathsAsLinks(CTX, "bwd", leftptrs, ldepth, chapter, context, maxdepth)
-			right_paths, Rnum = SST.GetEntireNCSuperConePathsAsLinks(CTX, "fwd", rightptrs, rdepth, chapter, context, maxdepth)
+			left_paths, right_paths, Lnum, Rnum, err = SuperConePair(CTX, "bwd", "fwd", leftptrs, rightptrs, ldepth, rdepth, chapter, context, maxdepth)
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
 
 			if Lnum == 0 || Rnum == 0 {
 				fmt.Println("No paths")
@@ -673,7 +699,7 @@ func HandleMatchingArrows(w http.ResponseWriter, r *http.Request, ctx SST.PoSST,
 
 	for a := range arrowptrs {
 		adir := SST.GetDBArrowByPtr(ctx, arrowptrs[a])
-		inv := SST.GetDBArrowByPtr(ctx, SST.INVERSE_ARROWS[arrowptrs[a]])
+		inv := SST.GetDBArrowByPtr(ctx, SST.GetInverseArrow(ctx, arrowptrs[a]))
 
 		var al ArrowList
 		al.ArrPtr = arrowptrs[a]
@@ -691,7 +717,7 @@ func HandleMatchingArrows(w http.ResponseWriter, r *http.Request, ctx SST.PoSST,
 		for st := range sttype {
 			adirs := SST.GetDBArrowBySTType(ctx, sttype[st])
 			for adir := range adirs {
-				inv := SST.GetDBArrowByPtr(ctx, SST.INVERSE_ARROWS[adirs[adir].Ptr])
+				inv := SST.GetDBArrowByPtr(ctx, SST.GetInverseArrow(ctx, adirs[adir].Ptr))
 
 				var al ArrowList
 				al.ArrPtr = adirs[adir].Ptr
@@ -867,6 +893,7 @@ func JSONStoryNodeEvent(en SST.NodeEvent) string {
 
 	jstr += fmt.Sprintf("\"Context\": \"%s\",\n", SST.EscapeString(en.Context))
 	jstr += fmt.Sprintf("\"NPtr\": { \"Class\": \"%d\", \"CPtr\" : \"%d\"},\n", en.NPtr.Class, en.NPtr.CPtr)
+	jstr += fmt.Sprintf("\"ID\": \"%s\",\n", en.ID)
 	jxyz, _ := json.Marshal(en.XYZ)
 	jstr += fmt.Sprintf("\"XYZ\": %s,\n", jxyz)
 
@@ -1364,6 +1364,11 @@ if (counter == 0)
    setting.id = "nptr-chapter-context-helpline";
 
    let text1 = document.createElement("i");
+   if (event.ID)
+      {
+      nptrtxt = nptrtxt + " or (#" + event.ID + ")";
+      }
+
    text1.textContent = "with NPtr " + nptrtxt + ", in chapter ";
    setting.appendChild(text1);
 
@@ -0,0 +1,105 @@
+//******************************************************************
+//
+// sstdump - write the whole database to a portable archive file
+//
+// The archive holds the nodes, page map, arrows, contexts and
+// LastSeen history as plain data, so it can be read back with
+// sstrestore into another database, or by a newer version
+//
+//******************************************************************
+
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+
+        SST "SSTorytime"
+)
+
+//******************************************************************
+// BEGIN
+//******************************************************************
+
+func main() {
+
+	args := Init()
+	file := args[0]
+
+	load_arrows := false
+	sst := SST.Open(load_arrows)
+
+	ar,err := SST.DumpDBErr(sst)
+
+	SST.Close(sst)
+
+	if err != nil {
+		fmt.Println("sstdump:",err)
+		os.Exit(-1)
+	}
+
+	err = WriteArchiveFile(file,ar)
+
+	if err != nil {
+		fmt.Println("sstdump:",err)
+		os.Exit(-1)
+	}
+
+	fmt.Printf("Wrote %s: %d nodes, %d page map lines, %d arrows, %d contexts, %d last seen (schema %d)\n",
+		file,len(ar.Nodes),len(ar.PageMap),len(ar.Arrows),len(ar.Contexts),len(ar.LastSeen),ar.Schema)
+}
+
+//**************************************************************
+
+func Init() []string {
+
+	flag.Usage = Usage
+
+	flag.Parse()
+
+	args := flag.Args()
+
+	if len(args) != 1 {
+		Usage()
+	}
+
+	return args
+}
+
+//**************************************************************
+
+func Usage() {
+	
+	fmt.Printf("\n\nusage: sstdump archive-file\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+//**************************************************************
+
+func WriteArchiveFile(file string,ar SST.Archive) error {
+
+	// Never leave half an archive behind
+
+	tmp := file+".tmp"
+
+	fd,err := os.Create(tmp)
+
+	if err != nil {
+		return err
+	}
+
+	err = SST.WriteArchive(fd,ar)
+
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp,file)
+}
@@ -0,0 +1,169 @@
+//******************************************************************
+//
+// sstmerge - merge chapters from another SST database into this one
+//
+// The source is another database, or an archive written by sstdump.
+// Nodes with the same text are joined, arrows and contexts are
+// matched by name, and every merged node records where it came from
+//
+//******************************************************************
+
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+	"strings"
+	"net/url"
+	"path/filepath"
+
+        SST "SSTorytime"
+)
+
+//******************************************************************
+
+var (
+	SOURCE_DB string
+	SOURCE_NAME string
+	CHAPTERS []string
+	FORCE bool
+)
+
+//******************************************************************
+// BEGIN
+//******************************************************************
+
+func main() {
+
+	args := Init()
+
+	ar,name := ReadSource(args)
+
+	if len(SOURCE_NAME) > 0 {
+		name = SOURCE_NAME
+	}
+
+	if ar.Schema > SST.LatestSchemaVersion() {
+		fmt.Printf("sstmerge: %s has schema version %d, newer than this program (%d)\n",name,ar.Schema,SST.LatestSchemaVersion())
+		os.Exit(-1)
+	}
+
+	load_arrows := true
+	sst := SST.Open(load_arrows)
+
+	conflicts := SST.GetArrowConflicts(sst,ar.Arrows)
+
+	for _,c := range conflicts {
+		fmt.Println(" conflict:",c)
+	}
+
+	if len(conflicts) > 0 && !FORCE {
+		fmt.Println("\nArrows above mean different things in the two databases. Rename them in one of them, or use -force to merge without their links.")
+		SST.Close(sst)
+		os.Exit(1)
+	}
+
+	merge,err := SST.MergeArchiveErr(sst,ar,CHAPTERS,name)
+
+	SST.Close(sst)
+
+	for _,w := range merge.Warnings {
+		fmt.Println(" ",w)
+	}
+
+	if err != nil {
+		fmt.Println("sstmerge:",err)
+		os.Exit(-1)
+	}
+
+	fmt.Println("Merged from",name+":",strings.Join(merge.Chapters,", "))
+	fmt.Println(" nodes inserted",merge.Sync.Inserted,"joined with existing",merge.Sync.Updated+merge.Sync.Unchanged,"page map lines added",merge.Sync.PageLines)
+	fmt.Println(" links to other chapters left out",merge.Outside,"by conflicting arrows",merge.Conflicting,"page map lines left out",merge.Lines)
+}
+
+//**************************************************************
+
+func Init() []string {
+
+	flag.Usage = Usage
+
+	dbPtr := flag.String("db","","merge from this database, given as a postgres:// URL, instead of an archive file")
+	namePtr := flag.String("name","","name recorded as the source of the merged nodes (default the database or file name)")
+	forcePtr := flag.Bool("force",false,"merge even if arrows conflict, leaving out their links")
+
+	flag.Func("chapter","merge only this chapter, may be repeated (default all)",func(chapter string) error {
+		CHAPTERS = append(CHAPTERS,chapter)
+		return nil
+	})
+
+	flag.Parse()
+
+	args := flag.Args()
+
+	SOURCE_DB = *dbPtr
+	SOURCE_NAME = *namePtr
+	FORCE = *forcePtr
+
+	if (len(SOURCE_DB) > 0) == (len(args) > 0) || len(args) > 1 {
+		Usage()
+	}
+
+	return args
+}
+
+//**************************************************************
+
+func Usage() {
+	
+	fmt.Printf("\n\nusage: sstmerge [-chapter name]... [-name source] [-force] (archive-file | -db postgres://...)\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+//**************************************************************
+
+func ReadSource(args []string) (SST.Archive,string) {
+
+	// The archive and a name for where it came from
+
+	if len(SOURCE_DB) > 0 {
+
+		var cfg SST.DBConfig
+		cfg.URL = SOURCE_DB
+
+		ar,err := SST.DumpDBWithConfigErr(cfg)
+
+		if err != nil {
+			fmt.Println("sstmerge:",err)
+			os.Exit(-1)
+		}
+
+		name := SOURCE_DB
+
+		if u,err := url.Parse(SOURCE_DB); err == nil && len(u.Host) > 0 {
+			name = u.Host+u.Path
+		}
+
+		return ar,name
+	}
+
+	file := args[0]
+
+	fd,err := os.Open(file)
+
+	if err != nil {
+		fmt.Println("sstmerge:",err)
+		os.Exit(-1)
+	}
+
+	ar,err := SST.ReadArchive(fd)
+	fd.Close()
+
+	if err != nil {
+		fmt.Println("sstmerge:",file+":",err)
+		os.Exit(-1)
+	}
+
+	return ar,strings.TrimSuffix(filepath.Base(file),filepath.Ext(file))
+}
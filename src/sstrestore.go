@@ -0,0 +1,120 @@
+//******************************************************************
+//
+// sstrestore - load an archive written by sstdump
+//
+// Into an empty database everything is restored as it was, with
+// the same node pointers. With -chapter, one chapter is merged into
+// a database that already has others, like N4L -sync
+//
+//******************************************************************
+
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+
+        SST "SSTorytime"
+)
+
+//******************************************************************
+
+var (
+	CHAPTER string
+)
+
+//******************************************************************
+// BEGIN
+//******************************************************************
+
+func main() {
+
+	args := Init()
+	file := args[0]
+
+	fd,err := os.Open(file)
+
+	if err != nil {
+		fmt.Println("sstrestore:",err)
+		os.Exit(-1)
+	}
+
+	ar,err := SST.ReadArchive(fd)
+	fd.Close()
+
+	if err != nil {
+		fmt.Println("sstrestore:",file+":",err)
+		os.Exit(-1)
+	}
+
+	if ar.Schema > SST.LatestSchemaVersion() {
+		fmt.Printf("sstrestore: %s was dumped from schema version %d, newer than this program (%d)\n",file,ar.Schema,SST.LatestSchemaVersion())
+		os.Exit(-1)
+	}
+
+	if CHAPTER != "" {
+
+		load_arrows := true
+		sst := SST.Open(load_arrows)
+
+		merge,err := SST.MergeArchiveChapterErr(sst,ar,CHAPTER)
+
+		SST.Close(sst)
+
+		if err != nil {
+			fmt.Println("sstrestore:",err)
+			os.Exit(-1)
+		}
+
+		fmt.Println("Merged chapter",CHAPTER,"from",file)
+		fmt.Println(" nodes inserted",merge.Sync.Inserted,"updated",merge.Sync.Updated,"unchanged",merge.Sync.Unchanged)
+		fmt.Println(" links to other chapters left out",merge.Outside,"page map lines left out",merge.Lines)
+		return
+	}
+
+	load_arrows := false
+	sst := SST.Open(load_arrows)
+
+	err = SST.RestoreArchiveErr(sst,ar)
+
+	SST.Close(sst)
+
+	if err != nil {
+		fmt.Println("sstrestore:",err)
+		os.Exit(-1)
+	}
+
+	fmt.Printf("Restored %s: %d nodes, %d page map lines, %d arrows, %d contexts, %d last seen\n",
+		file,len(ar.Nodes),len(ar.PageMap),len(ar.Arrows),len(ar.Contexts),len(ar.LastSeen))
+}
+
+//**************************************************************
+
+func Init() []string {
+
+	flag.Usage = Usage
+
+	chapterPtr := flag.String("chapter","","merge only this chapter into an existing database")
+
+	flag.Parse()
+
+	args := flag.Args()
+
+	if len(args) != 1 {
+		Usage()
+	}
+
+	CHAPTER = *chapterPtr
+
+	return args
+}
+
+//**************************************************************
+
+func Usage() {
+	
+	fmt.Printf("\n\nusage: sstrestore [-chapter name] archive-file\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
@@ -66,15 +66,15 @@ func Usage() {
 
 func RipFile2File(filename string,percentage float64){
 
-	SST.MemoryInit()
+	sst := SST.OpenMemory()
 
 	fmt.Println("Fractionating file...",filename)
-	psf,L := SST.FractionateTextFile(filename)
+	psf,L := SST.FractionateTextFile(sst,filename)
 
 	fmt.Println("Analyzing longitudinal patterns")
-	ranking1 := SelectByRunningIntent(psf,L,percentage)
+	ranking1 := SelectByRunningIntent(sst,psf,L,percentage)
 	fmt.Println("Analyzing statistical patterns")
-	ranking2 := SelectByStaticIntent(psf,L,percentage)
+	ranking2 := SelectByStaticIntent(sst,psf,L,percentage)
 	fmt.Println("Merging selections")
 	selection := MergeSelections(ranking1,ranking2)
 
@@ -86,7 +86,7 @@ func RipFile2File(filename string,percentage float64){
 	const minN = 1 // >= N_GRAM_MIN
 	const maxN = 3 // <= N_GRAM_MAX
 
-	f,s,ff,ss := SST.ExtractIntentionalTokens(L,selection,minN,maxN)
+	f,s,ff,ss := SST.ExtractIntentionalTokens(sst,L,selection,minN,maxN)
 
 	WriteOutput(filename,selection,L,percentage,f,s,ff,ss)
 }
@@ -226,7 +226,7 @@ func Sanitize(s string) string {
 
 //*******************************************************************
 
-func SelectByRunningIntent(psf [][][]string,L int,percentage float64) []SST.TextRank {
+func SelectByRunningIntent(sst SST.PoSST,psf [][][]string,L int,percentage float64) []SST.TextRank {
 
 	// Rank sentences
 
@@ -244,7 +244,7 @@ func SelectByRunningIntent(psf [][][]string,L int,percentage float64) []SST.Text
 
 			for f := 0; f < len(psf[p][s]); f++ {
 
-				score += SST.RunningIntentionality(sentence_counter,psf[p][s][f])
+				score += SST.RunningIntentionality(sst,sentence_counter,psf[p][s][f])
 
 				text += psf[p][s][f]
 
@@ -270,7 +270,7 @@ func SelectByRunningIntent(psf [][][]string,L int,percentage float64) []SST.Text
 
 // ***************************************************
 
-func SelectByStaticIntent(psf [][][]string,L int,percentage float64) []SST.TextRank {
+func SelectByStaticIntent(sst SST.PoSST,psf [][][]string,L int,percentage float64) []SST.TextRank {
 
 	// Rank sentences
 
@@ -288,7 +288,7 @@ func SelectByStaticIntent(psf [][][]string,L int,percentage float64) []SST.TextR
 
 			for f := 0; f < len(psf[p][s]); f++ {
 
-				score += SST.AssessStaticIntent(psf[p][s][f],L,SST.STM_NGRAM_FREQ,1)
+				score += SST.AssessStaticIntent(psf[p][s][f],L,sst.STMNgramFreq,1)
 
 				text += psf[p][s][f]
 